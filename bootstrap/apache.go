@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// apacheKinds lists the apache items of a config by kind, in the order they
+// are ensured. Modules come first as sites and confs may depend on them.
+func apacheKinds(apache *types.Apache) []struct {
+	kind  string
+	items *[]types.ApacheItem
+} {
+	return []struct {
+		kind  string
+		items *[]types.ApacheItem
+	}{
+		{target.ApacheModule, &apache.Modules},
+		{target.ApacheConf, &apache.Confs},
+		{target.ApacheSite, &apache.Sites},
+	}
+}
+
+// apacheName returns the name of the apache item for the report
+func apacheName(kind string, item types.ApacheItem) string {
+	state := item.State
+	if state == "" {
+		state = types.StateEnabled
+	}
+
+	return fmt.Sprintf("apache %s %s %s", kind, item.Name, state)
+}
+
+// ensureApache enables or disables the apache items on rmt, adds their outcome
+// to the report and notifies apache2. Changed modules need a restart.
+func ensureApache(rmt target.Host, address string, apache types.Apache, report *Report, h *handlers) {
+	for _, k := range apacheKinds(&apache) {
+		for _, item := range *k.items {
+			name := apacheName(k.kind, item)
+
+			status, err := rmt.EnsureApache(context.Background(), k.kind, item)
+			if err != nil {
+				fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+				report.Add(address, name, StatusFailed, err.Error())
+				continue
+			}
+
+			report.Add(address, name, statusName(status), "")
+			if status != types.StatusEnforced {
+				continue
+			}
+
+			if k.kind == target.ApacheModule {
+				h.notifyRestart("apache2")
+			} else {
+				h.notify("apache2")
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package bootstrap
 import (
 	"context"
 	"fmt"
+	"io"
+	"sync"
 
 	"io/ioutil"
 	"os"
@@ -20,33 +22,46 @@ const (
 
 type Client struct {
 	Configs []types.Config
+
+	// Report is the summary of the last Apply
+	Report Report
+
+	// Output receives the output of the commands while they are running, every
+	// line is prefixed with the host. Nothing is streamed when it's nil.
+	Output io.Writer
+
+	// outputMu serializes the lines of the hosts written to Output
+	outputMu sync.Mutex
 }
 
-func (bs *Client) Run(cpath, defaultpath string) error {
+// streams returns the writers streaming the output of host to Output, they are
+// nil when Output is not set
+func (bs *Client) streams(host string) (*prefixWriter, *prefixWriter) {
+	if bs.Output == nil {
+		return nil, nil
+	}
+
+	return newPrefixWriter(bs.Output, host, &bs.outputMu), newPrefixWriter(bs.Output, host, &bs.outputMu)
+}
+
+// Load reads the configs in cpath and adds the rules of their profile and the
+// defaults to them
+func (bs *Client) Load(cpath, defaultpath string) error {
 	configDir, err := os.ReadDir(cpath)
 	if err != nil {
 		return errors.Wrap(err, "config dir. is not found")
 	}
 
-	/**
-		TODO : purpose of `tmp`` is to compare the latest state with the new required
-	 configuration and produce the difference.
-
-	**/
-
-	// to create tmp dir. to keep state files
-	err = CheckDir(tmp)
+	defaultConfigs, err := defaultConfig(defaultpath)
 	if err != nil {
 		return err
 	}
 
-	defaultConfigs, err := defaultConfig(defaultpath)
+	groups, err := loadGroups(defaultpath)
 	if err != nil {
 		return err
 	}
 
-	fmt.Println("the following configuration are going to take place:")
-
 	for _, configFile := range configDir {
 		configFileContent, err := os.ReadFile(fmt.Sprintf("%s/%s", cpath, configFile.Name()))
 		if err != nil {
@@ -59,32 +74,86 @@ func (bs *Client) Run(cpath, defaultpath string) error {
 			return errors.Wrapf(err, "config file %s/%s is corrupted, err=%v", cpath, configFile.Name(), err)
 		}
 
-		// adding the defaults
-		// TODO : check for dupplications ?
-		config.Install = append(config.Install, defaultConfigs.Install...)
-		config.Run = append(config.Run, defaultConfigs.Run...)
-		config.Files = append(config.Files, defaultConfigs.Files...)
-
 		// validate host
 		if config.Host.Address == "" {
 			continue
 		}
 
+		// the groups and the defaults pick the profile when the host does not
+		err = applyGroups(&config, groups)
+		if err != nil {
+			return errors.Wrapf(err, "config file %s/%s", cpath, configFile.Name())
+		}
+
+		if config.Profile == "" {
+			config.Profile = defaultConfigs.Profile
+		}
+
+		if config.Profile != "" {
+			profile, err := loadProfile(config.Profile, config.Host.Address, config.Vars)
+			if err != nil {
+				return errors.Wrapf(err, "config file %s/%s", cpath, configFile.Name())
+			}
+			appendRules(&config, profile)
+		}
+
+		// adding the defaults
+		appendRules(&config, *defaultConfigs)
+
+		bs.Configs = append(bs.Configs, config)
+	}
+
+	return nil
+}
+
+func (bs *Client) Run(cpath, defaultpath string) error {
+	configs := len(bs.Configs)
+	err := bs.Load(cpath, defaultpath)
+	if err != nil {
+		return err
+	}
+
+	/**
+		TODO : purpose of `tmp`` is to compare the latest state with the new required
+	 configuration and produce the difference.
+
+	**/
+
+	// to create tmp dir. to keep state files
+	err = CheckDir(tmp)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("the following configuration are going to take place:")
+
+	plan := Report{}
+	for _, config := range bs.Configs[configs:] {
+		// the conditions are checked against the facts of the last run
+		filtered, err := applyConditions(config, cachedFacts(config.Host.Address), &plan)
+		if err != nil {
+			return errors.Wrapf(err, "config for %s", config.Host.Address)
+		}
+
+		// the certificates are validated and their expiry is shown before the apply
+		planCertificates(config.Host.Address, filtered.Certificates, &plan)
+
 		currentConfigBytes, err := yaml.Marshal(config)
 		if err != nil {
-			return errors.Wrapf(err, "marshaling config %s/%s", cpath, configFile.Name())
+			return errors.Wrapf(err, "marshaling config for %s", config.Host.Address)
 		}
 
 		err = ioutil.WriteFile(fmt.Sprintf("%s/%s.yaml", tmp, config.Host.Address), currentConfigBytes, 0644)
 		if err != nil {
-			return errors.Wrapf(err, "writing tmp config for %s/%s", cpath, configFile.Name())
+			return errors.Wrapf(err, "writing tmp config for %s", config.Host.Address)
 		}
 
-		bs.Configs = append(bs.Configs, config)
 		fmt.Println(string(currentConfigBytes))
 		fmt.Println("----------------")
 
 	}
+
+	plan.Print()
 	return nil
 }
 
@@ -102,11 +171,7 @@ func CheckDir(dir string) error {
 func (bs *Client) Apply() error {
 
 	for _, config := range bs.Configs {
-		addr := fmt.Sprintf("%s:%v", config.Host.Address, config.Host.Port)
-
-		// NOTE : ssh.InsecureIgnoreHostKey() is not production ready and it has to be
-		// changed with parsing the correct SSH Keys.
-		rmt, err := target.New(addr, config.Host.User, config.Host.Password, ssh.InsecureIgnoreHostKey(), ssh.Password(config.Host.Password))
+		rmt, err := connect(config)
 		if err != nil {
 			/*
 				 TODO:
@@ -120,6 +185,39 @@ func (bs *Client) Apply() error {
 
 		defer rmt.Close()
 
+		// stream the output of long running commands like apt-get
+		stdout, stderr := bs.streams(config.Host.Address)
+		if stdout != nil {
+			rmt.SetOutput(stdout, stderr)
+		}
+
+		// FACTS for the templates and the conditions, the host is skipped
+		// without them as the conditions on facts can not be evaluated
+		facts, err := gatherFacts(rmt, config.Host.Address)
+		if err != nil {
+			fmt.Printf("could not gather facts on %s with err=%v\n", config.Host.Address, err)
+			bs.Report.Add(config.Host.Address, "facts", StatusFailed, err.Error())
+			continue
+		}
+
+		// CONDITIONS remove the rules which do not apply to the host
+		config, err = applyConditions(config, facts, &bs.Report)
+		if err != nil {
+			fmt.Printf("could not check conditions on %s with err=%v\n", config.Host.Address, err)
+			continue
+		}
+
+		// REPOSITORIES and apt cache before touching any pkgs
+		changed, err := rmt.Repositories(context.Background(), config.Repositories)
+		if err != nil {
+			fmt.Printf("could not add repositories on %s with err=%v\n", config.Host.Address, err)
+		}
+
+		_, err = rmt.UpdateCache(context.Background(), changed, config.Apt.CacheValidTime)
+		if err != nil {
+			fmt.Printf("could not update apt cache on %s with err=%v\n", config.Host.Address, err)
+		}
+
 		// REMOVE pkgs
 		err = rmt.Remove(context.Background(), config.Remove)
 		if err != nil {
@@ -132,6 +230,24 @@ func (bs *Client) Apply() error {
 			fmt.Printf("could not install pkg on %s with err=%v\n", config.Host.Address, err)
 		}
 
+		// REBOOT when the installed pkgs need it, the remaining rules are
+		// applied on the new connection
+		var rebooted bool
+		rmt, rebooted, err = rebootIfRequired(rmt, config, &bs.Report)
+		if err != nil {
+			fmt.Printf("could not reboot %s with err=%v\n", config.Host.Address, err)
+			if rmt == nil {
+				continue
+			}
+		}
+
+		if rebooted {
+			defer rmt.Close()
+			if stdout != nil {
+				rmt.SetOutput(stdout, stderr)
+			}
+		}
+
 		// RUN services
 		err = rmt.Run(context.Background(), config.Run)
 		if err != nil {
@@ -144,24 +260,95 @@ func (bs *Client) Apply() error {
 			fmt.Printf("could not restart services on %s with err=%v\n", config.Host.Address, err)
 		}
 
+		// SYSTEM hostname, timezone and kernel parameters
+		ensureSystem(rmt, config.Host.Address, config, &bs.Report)
+
+		// FIREWALL once ufw or nftables is installed
+		ensureFirewall(rmt, config.Host.Address, config.Firewall, &bs.Report)
+
+		// USERS and groups before files, so they can own the pushed files
+		ensureUsers(rmt, config.Host.Address, config, &bs.Report)
+
+		// HANDLERS collect the services to reload when their config changes
+		notified := handlers{}
+
 		// PUSH files
-		err = rmt.Push(context.Background(), config.Files)
+		files, err := renderTemplates(config, facts)
 		if err != nil {
-			fmt.Printf("could not push file on %s with err=%v\n", config.Host.Address, err)
+			fmt.Printf("could not render templates for %s with err=%v\n", config.Host.Address, err)
+		} else {
+			ensureFiles(rmt, config.Host.Address, files, &bs.Report, &notified)
 		}
 
-		// restart apache
-		err = rmt.Restart(context.Background(), []types.Rule{"apache2"})
-		if err != nil {
-			fmt.Printf("could not push file on %s with err=%v\n", config.Host.Address, err)
+		// CERTIFICATES before the apache sites using them
+		ensureCertificates(rmt, config.Host.Address, config.Profile, config.Certificates, &bs.Report, &notified)
+
+		// LINES and BLOCKS tweak the pushed files and the distro defaults
+		editFiles(rmt, config.Host.Address, config.Lines, config.Blocks, &bs.Report, &notified)
+
+		// PHP_INI values, the services loading them are reloaded at the end
+		ensurePHPIni(rmt, config.Host.Address, config.PHPIni, &bs.Report, &notified)
+
+		// APACHE modules, confs and sites after their config files are in place
+		ensureApache(rmt, config.Host.Address, config.Apache, &bs.Report, &notified)
+
+		// SYNC directories like the application code
+		syncDirs(rmt, config.Host.Address, config.Sync, &bs.Report)
+
+		// CRON jobs once the code they run is in place
+		ensureCron(rmt, config.Host.Address, config.Cron, &bs.Report)
+
+		// SERVICES after files, so pushed unit files are picked up
+		ensureServices(rmt, config.Host.Address, config.Services, &bs.Report)
+
+		// EXEC commands and scripts after files and services are in place
+		runCommands(rmt, config.Host.Address, config.Exec, &bs.Report)
+		runScripts(rmt, config.Host.Address, config.Scripts, &bs.Report)
+
+		// FETCH files after the commands, so generated files are fetched too
+		fetchFiles(rmt, config.Host.Address, config.Fetch, &bs.Report)
+
+		// HANDLERS reload the services notified by changed config
+		notified.run(rmt, config.Host.Address, &bs.Report)
+
+		if stdout != nil {
+			stdout.Flush()
+			stderr.Flush()
 		}
 
 		fmt.Printf("%s configuration is done \n----------------------\n", config.Host.Address)
 	}
 
+	bs.Report.Print()
 	return nil
 }
 
+// connect returns a Remote for the host of config
+func connect(config types.Config) (target.Host, error) {
+	addr := fmt.Sprintf("%s:%v", config.Host.Address, config.Host.Port)
+
+	// NOTE : ssh.InsecureIgnoreHostKey() is not production ready and it has to be
+	// changed with parsing the correct SSH Keys.
+	rmt, err := target.New(addr, config.Host.User, config.Host.Password, ssh.InsecureIgnoreHostKey(), ssh.Password(config.Host.Password))
+	if err != nil {
+		return rmt, err
+	}
+
+	err = rmt.SetPackageManager(config.Host.PackageManager)
+	if err != nil {
+		rmt.Close()
+		return rmt, err
+	}
+
+	err = rmt.SetAptOptions(config.Apt)
+	if err != nil {
+		rmt.Close()
+		return rmt, err
+	}
+
+	return rmt, nil
+}
+
 func defaultConfig(dPath string) (*types.Config, error) {
 	dContent, err := os.ReadFile(dPath)
 	if err != nil {
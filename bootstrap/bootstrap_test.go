@@ -3,6 +3,7 @@ package bootstrap
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/slack/internal"
 	"github.com/slack/target/types"
@@ -85,15 +86,41 @@ func TestApply(t *testing.T) {
 					Address: internal.LocalAddr,
 					Port:    internal.LocalPort,
 				},
+				// the test server reports that a reboot is required
+				Reboot:   types.Reboot{IfRequired: true, Timeout: 5},
+				Services: []types.Service{{Name: "apache2", State: "restarted"}},
 			},
 		},
 	}
 
 	done := make(chan bool, 1)
 	go internal.SetupTestSSH(done)
+	// wait for the test server to listen
+	time.Sleep(200 * time.Millisecond)
+
+	fastReboot(t)
 
 	err := c.Apply()
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
 	}
+
+	rebooted, restarted := false, false
+	for _, e := range c.Report.Entries {
+		if e.Rule == "reboot" && e.Status == StatusEnforced {
+			rebooted = true
+		}
+
+		if e.Rule == "services apache2" && e.Status == StatusEnforced {
+			restarted = true
+		}
+	}
+
+	if !rebooted {
+		t.Errorf("expected the host to be rebooted and got %v", c.Report.Entries)
+	}
+
+	if !restarted {
+		t.Errorf("expected apache2 to be restarted and got %v", c.Report.Entries)
+	}
 }
@@ -0,0 +1,107 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// expiryWarning is the time before the expiry of a certificate it's reported as expiring
+const expiryWarning = 30 * 24 * time.Hour
+
+// certificateName returns the name of c for the report
+func certificateName(c types.Certificate) string {
+	return "certificate " + c.Name
+}
+
+// profileServers maps the profiles to the web server using their certificates
+var profileServers = map[string]string{
+	"apache-modphp": "apache2",
+	"nginx-fpm":     "nginx",
+}
+
+// certificateService returns the service reloaded when c changes, by default
+// the web server of the profile of the host or apache2
+func certificateService(c types.Certificate, profile string) string {
+	if c.Notify != "" {
+		return c.Notify
+	}
+
+	if server, ok := profileServers[profile]; ok {
+		return server
+	}
+
+	return "apache2"
+}
+
+// expiry returns the report status and the detail of a certificate expiring at
+// notAfter, the status is empty when it's not expiring soon
+func expiry(notAfter, now time.Time) (string, string) {
+	left := notAfter.Sub(now)
+	date := notAfter.UTC().Format("2006-01-02")
+
+	if left <= 0 {
+		return StatusExpired, "expired on " + date
+	}
+
+	detail := fmt.Sprintf("expires on %s in %d days", date, int(left.Hours()/24))
+	if left < expiryWarning {
+		return StatusExpiring, detail
+	}
+
+	return "", detail
+}
+
+// planCertificates validates the local certificate files and adds their expiry
+// to the plan
+func planCertificates(address string, certs []types.Certificate, report *Report) {
+	for _, c := range certs {
+		name := certificateName(c)
+
+		files, err := target.LoadCertificate(c)
+		if err != nil {
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		status, detail := expiry(files.Leaf.NotAfter, time.Now())
+		if status == "" {
+			status = StatusValid
+		}
+
+		report.Add(address, name, status, detail)
+	}
+}
+
+// ensureCertificates pushes the certificates to rmt, adds their outcome to the
+// report and notifies the services using the changed certificates
+func ensureCertificates(rmt target.Host, address, profile string, certs []types.Certificate, report *Report, h *handlers) {
+	for _, c := range certs {
+		name := certificateName(c)
+
+		status, err := rmt.EnsureCertificate(context.Background(), c)
+		if err != nil {
+			fmt.Printf("could not push %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		// the files are validated by EnsureCertificate, so they can be loaded
+		detail := ""
+		if files, err := target.LoadCertificate(c); err == nil {
+			var expiring string
+			expiring, detail = expiry(files.Leaf.NotAfter, time.Now())
+			if expiring != "" {
+				fmt.Printf("%s on %s %s\n", name, address, detail)
+			}
+		}
+
+		report.Add(address, name, statusName(status), detail)
+		if status == types.StatusEnforced {
+			h.notify(certificateService(c, profile))
+		}
+	}
+}
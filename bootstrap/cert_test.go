@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slack/internal"
+	"github.com/slack/target/types"
+)
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		notAfter time.Time
+		status   string
+		detail   string
+	}{
+		{now.Add(90 * 24 * time.Hour), "", "expires on 2026-04-01 in 90 days"},
+		{now.Add(10 * 24 * time.Hour), StatusExpiring, "expires on 2026-01-11 in 10 days"},
+		{now.Add(-time.Hour), StatusExpired, "expired on 2025-12-31"},
+	}
+
+	for _, c := range cases {
+		status, detail := expiry(c.notAfter, now)
+		if status != c.status || detail != c.detail {
+			t.Errorf("expected %v %v and got %v %v", c.status, c.detail, status, detail)
+		}
+	}
+}
+
+func TestPlanCertificates(t *testing.T) {
+	cert, key, chain, err := internal.WriteTestCertificate(t.TempDir(), time.Now().Add(5*24*time.Hour))
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	report := Report{}
+	planCertificates("127.0.0.1", []types.Certificate{
+		{Name: "site", Cert: cert, Key: key, Chain: chain},
+		{Name: "broken", Cert: cert, Key: chain},
+	}, &report)
+
+	if len(report.Entries) != 2 {
+		t.Fatalf("expected %v and got %v", 2, len(report.Entries))
+	}
+
+	if report.Entries[0].Status != StatusExpiring || report.Entries[1].Status != StatusFailed {
+		t.Errorf("expected %v and got %v", []string{StatusExpiring, StatusFailed}, report.Entries)
+	}
+
+	services := map[string]string{
+		certificateService(types.Certificate{}, ""):                           "apache2",
+		certificateService(types.Certificate{}, "nginx-fpm"):                  "nginx",
+		certificateService(types.Certificate{Notify: "haproxy"}, "nginx-fpm"): "haproxy",
+	}
+
+	for service, expected := range services {
+		if service != expected {
+			t.Errorf("expected %v and got %v", expected, service)
+		}
+	}
+}
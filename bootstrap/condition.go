@@ -0,0 +1,623 @@
+package bootstrap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// errUnknownFacts is returned when a condition uses facts which have not been
+// gathered yet, e.g. in the plan before the first apply
+var errUnknownFacts = errors.New("facts are not gathered yet")
+
+// conditionEnv returns the values available to conditions: facts, vars and
+// host, facts are nil when they are unknown
+func conditionEnv(config types.Config, facts *types.Facts) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for k, v := range config.Vars {
+		vars[k] = v
+	}
+
+	env := map[string]interface{}{
+		"vars": vars,
+		"host": map[string]interface{}{
+			"address": config.Host.Address,
+		},
+		"facts": nil,
+	}
+
+	if facts == nil {
+		return env, nil
+	}
+
+	// the facts are exposed with their json names
+	factsBytes, err := json.Marshal(facts)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshaling facts")
+	}
+
+	var factsMap map[string]interface{}
+	err = json.Unmarshal(factsBytes, &factsMap)
+	if err != nil {
+		return nil, errors.Wrap(err, "unmarshaling facts")
+	}
+
+	env["facts"] = factsMap
+	return env, nil
+}
+
+// evalCondition evaluates a when-condition like
+//
+//	facts.os.id == "ubuntu" && facts.memory.total_mb > 4096
+//	vars.debug || !("apache2" in facts.services)
+//
+// identifiers are dotted paths into the env, a bare identifier is true when it
+// is set and not empty, 0 or false
+func evalCondition(expr string, env map[string]interface{}) (bool, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return false, err
+	}
+
+	p := parser{tokens: tokens, env: env}
+	v, err := p.or()
+	if err != nil {
+		return false, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return false, fmt.Errorf("unexpected %s in condition %q", p.tokens[p.pos].value, expr)
+	}
+
+	return truthy(v), nil
+}
+
+type tokenKind int
+
+const (
+	tokenIdent tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenOp
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// tokenize splits a condition into identifiers, strings, numbers and operators
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	ops := []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '"' || c == '\'':
+			end := strings.IndexRune(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in condition %q", expr)
+			}
+			tokens = append(tokens, token{tokenString, expr[i+1 : i+1+end]})
+			i += end + 2
+
+		case unicode.IsDigit(c):
+			j := i
+			for j < len(expr) && (unicode.IsDigit(rune(expr[j])) || expr[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, token{tokenNumber, expr[i:j]})
+			i = j
+
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) || strings.ContainsRune("_.-", rune(expr[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{tokenIdent, expr[i:j]})
+			i = j
+
+		default:
+			found := false
+			for _, op := range ops {
+				if strings.HasPrefix(expr[i:], op) {
+					tokens = append(tokens, token{tokenOp, op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return nil, fmt.Errorf("unexpected %q in condition %q", c, expr)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+// parser is a recursive descent parser evaluating the condition while parsing.
+// The right side of || and && is parsed but not evaluated when the left side
+// decides the result, so unknown facts are not an error there.
+type parser struct {
+	tokens []token
+	pos    int
+	env    map[string]interface{}
+
+	// skip is set while parsing a side which is not evaluated
+	skip int
+}
+
+func (p *parser) peek(value string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind != tokenString && p.tokens[p.pos].value == value
+}
+
+func (p *parser) or() (interface{}, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("||") {
+		p.pos++
+
+		decided := truthy(left)
+		if decided {
+			p.skip++
+		}
+
+		right, err := p.and()
+		if decided {
+			p.skip--
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		left = decided || truthy(right)
+	}
+
+	return left, nil
+}
+
+func (p *parser) and() (interface{}, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek("&&") {
+		p.pos++
+
+		decided := !truthy(left)
+		if decided {
+			p.skip++
+		}
+
+		right, err := p.not()
+		if decided {
+			p.skip--
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		left = !decided && truthy(right)
+	}
+
+	return left, nil
+}
+
+func (p *parser) not() (interface{}, error) {
+	if p.peek("!") {
+		p.pos++
+		v, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return !truthy(v), nil
+	}
+
+	return p.comparison()
+}
+
+func (p *parser) comparison() (interface{}, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "in"} {
+		if !p.peek(op) {
+			continue
+		}
+
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+
+		if op == "in" {
+			return contains(right, left), nil
+		}
+
+		return compare(left, right, op), nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) operand() (interface{}, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, errors.New("unexpected end of condition")
+	}
+
+	t := p.tokens[p.pos]
+	p.pos++
+
+	switch t.kind {
+	case tokenString:
+		return t.value, nil
+	case tokenNumber:
+		return strconv.ParseFloat(t.value, 64)
+	case tokenIdent:
+		switch t.value {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return p.lookup(t.value)
+	}
+
+	if t.value == "(" {
+		v, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.peek(")") {
+			return nil, errors.New("missing ) in condition")
+		}
+		p.pos++
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %s in condition", t.value)
+}
+
+// lookup resolves a dotted path in the env, unset values are nil
+func (p *parser) lookup(path string) (interface{}, error) {
+	parts := strings.Split(path, ".")
+
+	root, ok := p.env[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown identifier %s, conditions can use facts, vars and host", path)
+	}
+
+	if parts[0] == "facts" && root == nil {
+		if p.skip > 0 {
+			return nil, nil
+		}
+		return nil, errUnknownFacts
+	}
+
+	var v interface{} = root
+	for _, part := range parts[1:] {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		v = m[part]
+	}
+
+	return v, nil
+}
+
+// truthy is false for unset, empty, 0 and false values
+func truthy(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0
+	case string:
+		return t != "" && t != "0" && t != "false"
+	case []interface{}:
+		return len(t) > 0
+	case map[string]interface{}:
+		return len(t) > 0
+	}
+
+	return true
+}
+
+// compare compares numerically when one value is a number and the other is a
+// number or numeric string, otherwise as strings
+func compare(left, right interface{}, op string) bool {
+	if left == nil || right == nil {
+		switch op {
+		case "==":
+			return left == nil && right == nil
+		case "!=":
+			return !(left == nil && right == nil)
+		}
+		return false
+	}
+
+	// two strings are compared as strings, so versions like 22.10 and 22.1 differ
+	_, lnum := left.(float64)
+	_, rnum := right.(float64)
+
+	l, lok := number(left)
+	r, rok := number(right)
+	if (lnum || rnum) && lok && rok {
+		switch op {
+		case "==":
+			return l == r
+		case "!=":
+			return l != r
+		case "<":
+			return l < r
+		case "<=":
+			return l <= r
+		case ">":
+			return l > r
+		case ">=":
+			return l >= r
+		}
+	}
+
+	ls, rs := fmt.Sprint(left), fmt.Sprint(right)
+	switch op {
+	case "==":
+		return ls == rs
+	case "!=":
+		return ls != rs
+	case "<":
+		return ls < rs
+	case "<=":
+		return ls <= rs
+	case ">":
+		return ls > rs
+	case ">=":
+		return ls >= rs
+	}
+
+	return false
+}
+
+// number converts numbers and numeric strings to float64
+func number(v interface{}) (float64, bool) {
+	switch t := v.(type) {
+	case float64:
+		return t, true
+	case string:
+		f, err := strconv.ParseFloat(t, 64)
+		return f, err == nil
+	}
+
+	return 0, false
+}
+
+// contains checks if list contains v, or if map has the key v
+func contains(list, v interface{}) bool {
+	switch t := list.(type) {
+	case []interface{}:
+		for _, item := range t {
+			if compare(item, v, "==") {
+				return true
+			}
+		}
+	case map[string]interface{}:
+		_, ok := t[fmt.Sprint(v)]
+		return ok
+	case string:
+		return strings.Contains(t, fmt.Sprint(v))
+	}
+
+	return false
+}
+
+// applyConditions removes the rules of config whose when-condition is false and
+// reports them as skipped. Without facts, the rules with conditions on facts are
+// kept and reported as unknown.
+func applyConditions(config types.Config, facts *types.Facts, report *Report) (types.Config, error) {
+	env, err := conditionEnv(config, facts)
+	if err != nil {
+		return config, err
+	}
+
+	var condErr error
+	keep := func(rule, when string) bool {
+		if when == "" || condErr != nil {
+			return condErr == nil
+		}
+
+		ok, err := evalCondition(when, env)
+		if err == errUnknownFacts {
+			report.Add(config.Host.Address, rule, StatusUnknown, "when "+when)
+			return true
+		}
+
+		if err != nil {
+			condErr = errors.Wrapf(err, "invalid condition of %s", rule)
+			return false
+		}
+
+		if !ok {
+			report.Add(config.Host.Address, rule, StatusSkipped, "when "+when)
+		}
+		return ok
+	}
+
+	filtered := config
+
+	filtered.Install = nil
+	for _, p := range config.Install {
+		name := p.Name
+		if p.Deb != "" {
+			name = p.Deb
+		}
+
+		if keep("install "+name, p.When) {
+			filtered.Install = append(filtered.Install, p)
+		}
+	}
+
+	rules := []struct {
+		kind string
+		list *types.Rules
+	}{
+		{"remove", &filtered.Remove},
+		{"run", &filtered.Run},
+		{"restart", &filtered.Restart},
+	}
+	for _, rule := range rules {
+		all := *rule.list
+		*rule.list = nil
+		for _, r := range all {
+			if keep(rule.kind+" "+r.Name, r.When) {
+				*rule.list = append(*rule.list, r)
+			}
+		}
+	}
+
+	filtered.Files = nil
+	for _, f := range config.Files {
+		if keep(fileName(f), f.When) {
+			filtered.Files = append(filtered.Files, f)
+		}
+	}
+
+	filtered.Sync = nil
+	for _, sc := range config.Sync {
+		if keep(syncName(sc), sc.When) {
+			filtered.Sync = append(filtered.Sync, sc)
+		}
+	}
+
+	filtered.Fetch = nil
+	for _, f := range config.Fetch {
+		if keep(fetchName(f), f.When) {
+			filtered.Fetch = append(filtered.Fetch, f)
+		}
+	}
+
+	filtered.Certificates = nil
+	for _, c := range config.Certificates {
+		if keep(certificateName(c), c.When) {
+			filtered.Certificates = append(filtered.Certificates, c)
+		}
+	}
+
+	filtered.Lines = nil
+	for _, l := range config.Lines {
+		if keep(lineName(l), l.When) {
+			filtered.Lines = append(filtered.Lines, l)
+		}
+	}
+
+	filtered.Blocks = nil
+	for _, b := range config.Blocks {
+		if keep(blockName(b), b.When) {
+			filtered.Blocks = append(filtered.Blocks, b)
+		}
+	}
+
+	filtered.PHPIni = nil
+	for _, p := range config.PHPIni {
+		if keep(phpIniName(p), p.When) {
+			filtered.PHPIni = append(filtered.PHPIni, p)
+		}
+	}
+
+	filtered.Apache = types.Apache{}
+	original := config.Apache
+	kinds := apacheKinds(&filtered.Apache)
+	for i, k := range apacheKinds(&original) {
+		for _, item := range *k.items {
+			if keep(apacheName(k.kind, item), item.When) {
+				*kinds[i].items = append(*kinds[i].items, item)
+			}
+		}
+	}
+
+	filtered.Sysctl = nil
+	for _, sc := range config.Sysctl {
+		if keep(sysctlName(sc), sc.When) {
+			filtered.Sysctl = append(filtered.Sysctl, sc)
+		}
+	}
+
+	filtered.Cron = nil
+	for _, c := range config.Cron {
+		if keep(cronName(c), c.When) {
+			filtered.Cron = append(filtered.Cron, c)
+		}
+	}
+
+	filtered.Firewall.Rules = nil
+	for _, rule := range config.Firewall.Rules {
+		if keep(firewallRuleName(rule), rule.When) {
+			filtered.Firewall.Rules = append(filtered.Firewall.Rules, rule)
+		}
+	}
+
+	filtered.UserGroups = nil
+	for _, g := range config.UserGroups {
+		if keep(userGroupName(g), g.When) {
+			filtered.UserGroups = append(filtered.UserGroups, g)
+		}
+	}
+
+	filtered.Users = nil
+	for _, u := range config.Users {
+		if keep(userName(u), u.When) {
+			filtered.Users = append(filtered.Users, u)
+		}
+	}
+
+	filtered.AuthorizedKeys = nil
+	for _, k := range config.AuthorizedKeys {
+		if keep(authorizedKeyName(k), k.When) {
+			filtered.AuthorizedKeys = append(filtered.AuthorizedKeys, k)
+		}
+	}
+
+	filtered.Services = nil
+	for _, s := range config.Services {
+		if keep(serviceName(s), s.When) {
+			filtered.Services = append(filtered.Services, s)
+		}
+	}
+
+	filtered.Exec = nil
+	for _, c := range config.Exec {
+		if keep(commandName(c), c.When) {
+			filtered.Exec = append(filtered.Exec, c)
+		}
+	}
+
+	filtered.Scripts = nil
+	for _, sc := range config.Scripts {
+		if keep(scriptName(sc), sc.When) {
+			filtered.Scripts = append(filtered.Scripts, sc)
+		}
+	}
+
+	return filtered, condErr
+}
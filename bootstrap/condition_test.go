@@ -0,0 +1,137 @@
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestEvalCondition(t *testing.T) {
+	config := types.Config{
+		Host: types.Host{Address: "127.0.0.1"},
+		Vars: map[string]string{"debug": "true", "env": "prod", "off": "false"},
+	}
+
+	facts := &types.Facts{
+		OS:       types.OS{ID: "ubuntu", Version: "22.04"},
+		Memory:   types.Memory{TotalMB: 8192},
+		Packages: map[string]string{"php8.1": "8.1.2"},
+		Services: []string{"apache2", "ssh"},
+	}
+
+	env, err := conditionEnv(config, facts)
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	cases := map[string]bool{
+		`facts.os.id == "ubuntu" && facts.os.version == "22.04"`: true,
+		`facts.os.id == "ubuntu" && facts.os.version == "22.4"`:  false,
+		`facts.memory.total_mb > 4096`:                           true,
+		`facts.memory.total_mb <= 4096`:                          false,
+		`vars.debug`:                                             true,
+		`vars.off || vars.missing`:                               false,
+		`!vars.missing`:                                          true,
+		`vars.env != 'dev' && (vars.missing || vars.debug)`:      true,
+		`"apache2" in facts.services`:                            true,
+		`!("nginx" in facts.services)`:                           true,
+		`"php8.1" in facts.packages`:                             true,
+		`host.address == "127.0.0.1"`:                            true,
+	}
+
+	for expr, expected := range cases {
+		ok, err := evalCondition(expr, env)
+		if err != nil {
+			t.Errorf("%s: expected no errors and got err=%v", expr, err.Error())
+		}
+
+		if ok != expected {
+			t.Errorf("%s: expected %v and got %v", expr, expected, ok)
+		}
+	}
+
+	for _, expr := range []string{`facts.os.id ==`, `(vars.debug`, `vars.debug)`, `"ubuntu`, `os.id == "ubuntu"`, `vars.a = 1`} {
+		_, err := evalCondition(expr, env)
+		if err == nil {
+			t.Errorf("%s: expected error and got nil", expr)
+		}
+	}
+
+	env, _ = conditionEnv(config, nil)
+	for _, expr := range []string{`facts.os.id == "ubuntu"`, `vars.missing || facts.os.id == "ubuntu"`, `vars.debug && facts.os.id == "ubuntu"`, `facts.os.id == "ubuntu" || true`} {
+		_, err = evalCondition(expr, env)
+		if err != errUnknownFacts {
+			t.Errorf("%s: expected %v and got %v", expr, errUnknownFacts, err)
+		}
+	}
+
+	// the side which does not decide the result is not evaluated
+	shortCircuits := map[string]bool{
+		`true || facts.os.id == "ubuntu"`:                   true,
+		`vars.debug || facts.memory.total_mb > 4096`:        true,
+		`false && facts.os.id == "ubuntu"`:                  false,
+		`vars.missing && (facts.os.id == "ubuntu" || true)`: false,
+		`(false && facts.os.id == "debian") || vars.debug`:  true,
+	}
+
+	for expr, expected := range shortCircuits {
+		ok, err := evalCondition(expr, env)
+		if err != nil || ok != expected {
+			t.Errorf("%s: expected %v and got %v %v", expr, expected, ok, err)
+		}
+	}
+
+	// parse errors are still reported in the side which is not evaluated
+	_, err = evalCondition(`true || (facts.os.id ==`, env)
+	if err == nil || err == errUnknownFacts {
+		t.Errorf("expected a parse error and got %v", err)
+	}
+}
+
+func TestApplyConditions(t *testing.T) {
+	config := types.Config{
+		Host: types.Host{Address: "127.0.0.1"},
+		Install: types.Packages{
+			{Name: "apache2"},
+			{Name: "php", When: `facts.os.id == "debian"`},
+		},
+		Run: types.Rules{
+			{Name: "apache2", When: `vars.web`},
+		},
+		Files: []types.File{
+			{RemotePath: "/root/hello.txt", When: `facts.os.id == "ubuntu"`},
+		},
+		Vars: map[string]string{"web": "1"},
+	}
+
+	report := Report{}
+	filtered, err := applyConditions(config, &types.Facts{OS: types.OS{ID: "ubuntu"}}, &report)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(filtered.Install) != 1 || len(filtered.Run) != 1 || len(filtered.Files) != 1 {
+		t.Errorf("expected %v and got %v", "php to be skipped", filtered)
+	}
+
+	if len(report.Entries) != 1 || report.Entries[0].Status != StatusSkipped || report.Entries[0].Rule != "install php" {
+		t.Errorf("expected %v and got %v", "install php skipped", report.Entries)
+	}
+
+	// unknown facts keep the rules
+	report = Report{}
+	filtered, err = applyConditions(config, nil, &report)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(filtered.Install) != 2 || len(report.Entries) != 2 || report.Entries[0].Status != StatusUnknown {
+		t.Errorf("expected %v and got %v", "2 unknown conditions", report.Entries)
+	}
+
+	config.Services = []types.Service{{Name: "apache2", When: "vars.web =="}}
+	_, err = applyConditions(config, nil, &report)
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
@@ -0,0 +1,174 @@
+package bootstrap
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+	"golang.org/x/sync/errgroup"
+)
+
+// commandName returns the name of c for the report
+func commandName(c types.Command) string {
+	if c.Name != "" {
+		return "exec " + c.Name
+	}
+
+	return "exec " + c.Command
+}
+
+// scriptName returns the name of s for the report
+func scriptName(s types.Script) string {
+	if s.Name != "" {
+		return "script " + s.Name
+	}
+
+	return "script " + s.Path
+}
+
+// runCommands runs the commands on rmt and adds their outcome to the report
+func runCommands(rmt target.Host, address string, commands []types.Command, report *Report) {
+	for _, c := range commands {
+		name := commandName(c)
+		status, res, err := execute(address, name, func() (types.StatusCode, types.Response, error) {
+			return rmt.Exec(context.Background(), c)
+		})
+		addResult(report, address, name, status, res, err)
+	}
+}
+
+// runScripts runs the scripts on rmt and adds their outcome to the report
+func runScripts(rmt target.Host, address string, scripts []types.Script, report *Report) {
+	for _, s := range scripts {
+		name := scriptName(s)
+		status, res, err := execute(address, name, func() (types.StatusCode, types.Response, error) {
+			return rmt.RunScript(context.Background(), s)
+		})
+		addResult(report, address, name, status, res, err)
+	}
+}
+
+// execute runs a command or script rule and logs its progress
+func execute(address, name string, run func() (types.StatusCode, types.Response, error)) (types.StatusCode, types.Response, error) {
+	fmt.Printf("trying to %s on %s ...\n", name, address)
+
+	status, res, err := run()
+	if err != nil {
+		fmt.Printf("could not %s on %s with err=%v\n", name, address, err)
+		return status, res, err
+	}
+
+	fmt.Printf("%s is done on %s\n", name, address)
+	return status, res, nil
+}
+
+// addResult adds the outcome of a command or script rule to the report
+func addResult(report *Report, address, name string, status types.StatusCode, res types.Response, err error) {
+	if err != nil {
+		report.AddOutput(address, name, StatusFailed, err.Error(), res)
+		return
+	}
+
+	// the output of a satisfied rule is the guard which short-circuited it
+	if status == types.StatusSatisfied {
+		report.Add(address, name, StatusSatisfied, res.Stdout.String())
+		return
+	}
+
+	report.AddOutput(address, name, statusName(status), fmt.Sprintf("exit %d", res.ExitStatus), res)
+}
+
+// HostResult is the outcome of an ad-hoc command on a host
+type HostResult struct {
+	Address  string
+	Response types.Response
+	Err      error
+}
+
+// Success checks if the command ran and exited with 0
+func (h HostResult) Success() bool {
+	return h.Err == nil && h.Response.Success()
+}
+
+// matchHost checks if the host of config matches one of the comma separated
+// glob patterns, an empty filter matches all hosts
+func matchHost(config types.Config, filter string) (bool, error) {
+	if filter == "" {
+		return true, nil
+	}
+
+	for _, pattern := range strings.Split(filter, ",") {
+		ok, err := path.Match(strings.TrimSpace(pattern), config.Host.Address)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid host pattern %s", pattern)
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// matchHosts returns the configs of the hosts matching filter
+func (bs *Client) matchHosts(filter string) ([]types.Config, error) {
+	configs := []types.Config{}
+	for _, config := range bs.Configs {
+		ok, err := matchHost(config, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			configs = append(configs, config)
+		}
+	}
+
+	return configs, nil
+}
+
+// Exec runs cmd on the hosts matching filter in parallel and returns the
+// result of every host in the order of the configs. The output is streamed to
+// Output while the command is running when it's set.
+func (bs *Client) Exec(cmd, filter string) ([]HostResult, error) {
+	configs, err := bs.matchHosts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]HostResult, len(configs))
+	errs, _ := errgroup.WithContext(context.Background())
+
+	for i, c := range configs {
+		i, config := i, c
+		errs.Go(func() error {
+			results[i].Address = config.Host.Address
+
+			rmt, err := connect(config)
+			if err != nil {
+				results[i].Err = err
+				return nil
+			}
+			defer rmt.Close()
+
+			stdout, stderr := bs.streams(config.Host.Address)
+			if stdout == nil {
+				results[i].Response, results[i].Err = rmt.RunCmd(cmd, bytes.NewBufferString(""))
+				return nil
+			}
+
+			results[i].Response, results[i].Err = rmt.RunCmdStream(cmd, bytes.NewBufferString(""), stdout, stderr)
+			stdout.Flush()
+			stderr.Flush()
+			return nil
+		})
+	}
+
+	return results, errs.Wait()
+}
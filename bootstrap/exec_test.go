@@ -0,0 +1,63 @@
+package bootstrap
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestMatchHost(t *testing.T) {
+	config := types.Config{Host: types.Host{Address: "10.0.1.12"}}
+
+	cases := map[string]bool{
+		"":                     true,
+		"10.0.1.12":            true,
+		"10.0.1.*":             true,
+		"10.0.2.*, 10.0.1.1?":  true,
+		"10.0.2.*":             false,
+		"192.168.*,172.16.*.*": false,
+	}
+
+	for filter, expected := range cases {
+		ok, err := matchHost(config, filter)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if ok != expected {
+			t.Errorf("%s: expected %v and got %v", filter, expected, ok)
+		}
+	}
+
+	_, err := matchHost(config, "10.0.[1")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
+
+func TestExec(t *testing.T) {
+	c := Client{
+		Configs: []types.Config{
+			{Host: types.Host{Address: "localhost", Port: 1}},
+			{Host: types.Host{Address: "127.0.0.1", Port: 1}},
+		},
+	}
+
+	results, err := c.Exec("df -h", "localhost")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(results) != 1 || results[0].Address != "localhost" {
+		t.Fatalf("expected %v and got %v", "localhost only", results)
+	}
+
+	if results[0].Success() {
+		t.Errorf("expected unreachable host to fail")
+	}
+
+	_, err = c.Exec("df -h", "[")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
@@ -0,0 +1,84 @@
+package bootstrap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// factsPath returns the path of the cached facts of address in the tmp dir
+func factsPath(address string) string {
+	return fmt.Sprintf("%s/%s.facts.json", tmp, address)
+}
+
+// gatherFacts gathers the facts of rmt and caches them in the tmp dir
+func gatherFacts(rmt target.Host, address string) (*types.Facts, error) {
+	facts, err := rmt.Facts(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	err = CheckDir(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	factsBytes, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshaling facts of %s", address)
+	}
+
+	err = ioutil.WriteFile(factsPath(address), factsBytes, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "writing tmp facts for %s", address)
+	}
+
+	return facts, nil
+}
+
+// cachedFacts returns the facts cached by the last run, nil if there are none
+func cachedFacts(address string) *types.Facts {
+	factsBytes, err := os.ReadFile(factsPath(address))
+	if err != nil {
+		return nil
+	}
+
+	var facts types.Facts
+	err = json.Unmarshal(factsBytes, &facts)
+	if err != nil {
+		return nil
+	}
+
+	return &facts
+}
+
+// Facts gathers the facts of all hosts, keyed by the host address.
+// Hosts which are not reachable are reported and skipped.
+func (bs *Client) Facts() (map[string]*types.Facts, error) {
+	allFacts := map[string]*types.Facts{}
+
+	for _, config := range bs.Configs {
+		rmt, err := connect(config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not get new host %s: %v\n", config.Host.Address, err)
+			continue
+		}
+
+		facts, err := gatherFacts(rmt, config.Host.Address)
+		rmt.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "could not gather facts on %s with err=%v\n", config.Host.Address, err)
+			continue
+		}
+
+		allFacts[config.Host.Address] = facts
+	}
+
+	return allFacts, nil
+}
@@ -0,0 +1,50 @@
+package bootstrap
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestFacts(t *testing.T) {
+	defer os.RemoveAll(tmp)
+
+	c := Client{
+		Configs: []types.Config{
+			{
+				Host: types.Host{
+					Address: "localhost",
+					Port:    1,
+				},
+			},
+		},
+	}
+
+	facts, err := c.Facts()
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(facts) != 0 {
+		t.Errorf("expected %v and got %v", 0, len(facts))
+	}
+
+	// cached facts
+	address := "192.0.2.1"
+	if cachedFacts(address) != nil {
+		t.Errorf("expected no cached facts for %s", address)
+	}
+
+	CheckDir(tmp)
+	err = ioutil.WriteFile(factsPath(address), []byte(`{"os":{"id":"ubuntu","version":"22.04"}}`), 0644)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	cached := cachedFacts(address)
+	if cached == nil || cached.OS.ID != "ubuntu" {
+		t.Errorf("expected %v and got %v", "ubuntu", cached)
+	}
+}
@@ -0,0 +1,101 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// fetchDir is the default local directory of the fetched files
+	fetchDir = "fetched"
+)
+
+// fetchPath returns the local path of a remote file fetched from address,
+// remote paths can not escape the directory of the host
+func fetchPath(dir, address, remotePath string) string {
+	if dir == "" {
+		dir = fetchDir
+	}
+
+	return filepath.Join(dir, address, filepath.FromSlash(path.Clean("/"+remotePath)))
+}
+
+// fetchName returns the name of f for the report
+func fetchName(f types.Fetch) string {
+	return "fetch " + f.RemotePath
+}
+
+// fetchFiles fetches the files from rmt and adds their outcome to the report
+func fetchFiles(rmt target.Host, address string, fetches []types.Fetch, report *Report) {
+	for _, f := range fetches {
+		name := fetchName(f)
+		localPath := fetchPath(f.LocalPath, address, f.RemotePath)
+
+		status, err := rmt.Fetch(context.Background(), f.RemotePath, localPath)
+		if err != nil {
+			fmt.Printf("could not %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "to "+localPath)
+	}
+}
+
+// FetchResult is the outcome of fetching a file from a host
+type FetchResult struct {
+	Address    string
+	RemotePath string
+	LocalPath  string
+	Status     types.StatusCode
+	Err        error
+}
+
+// Fetch downloads the remote paths from the hosts matching filter in parallel
+// into <dir>/<host address>/<remote path>, dir defaults to fetched
+func (bs *Client) Fetch(remotePaths []string, dir, filter string) ([]FetchResult, error) {
+	configs, err := bs.matchHosts(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]FetchResult, len(configs)*len(remotePaths))
+	errs, _ := errgroup.WithContext(context.Background())
+
+	for i, c := range configs {
+		i, config := i, c
+		errs.Go(func() error {
+			hostResults := results[i*len(remotePaths) : (i+1)*len(remotePaths)]
+			for j, remotePath := range remotePaths {
+				hostResults[j] = FetchResult{
+					Address:    config.Host.Address,
+					RemotePath: remotePath,
+					LocalPath:  fetchPath(dir, config.Host.Address, remotePath),
+					Status:     types.StatusFailed,
+				}
+			}
+
+			rmt, err := connect(config)
+			if err != nil {
+				for j := range hostResults {
+					hostResults[j].Err = err
+				}
+				return nil
+			}
+			defer rmt.Close()
+
+			for j := range hostResults {
+				hostResults[j].Status, hostResults[j].Err = rmt.Fetch(context.Background(), hostResults[j].RemotePath, hostResults[j].LocalPath)
+			}
+			return nil
+		})
+	}
+
+	return results, errs.Wait()
+}
@@ -0,0 +1,23 @@
+package bootstrap
+
+import (
+	"testing"
+)
+
+func TestFetchPath(t *testing.T) {
+	cases := map[string]string{
+		"/var/log/apache2/error.log": "fetched/10.0.1.5/var/log/apache2/error.log",
+		"etc/php.ini":                "fetched/10.0.1.5/etc/php.ini",
+		"../../etc/passwd":           "fetched/10.0.1.5/etc/passwd",
+	}
+
+	for remotePath, expected := range cases {
+		if p := fetchPath("", "10.0.1.5", remotePath); p != expected {
+			t.Errorf("expected %v and got %v", expected, p)
+		}
+	}
+
+	if p := fetchPath("logs", "10.0.1.5", "/var/log/syslog"); p != "logs/10.0.1.5/var/log/syslog" {
+		t.Errorf("expected %v and got %v", "logs/10.0.1.5/var/log/syslog", p)
+	}
+}
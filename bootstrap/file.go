@@ -0,0 +1,88 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// fileName returns the name of f for the report
+func fileName(f types.File) string {
+	return "transfer_files " + f.RemotePath
+}
+
+// ensureFiles ensures the transfer_files entries on rmt, adds their outcome
+// to the report and notifies the services loading the changed files
+func ensureFiles(rmt target.Host, address string, files []types.File, report *Report, h *handlers) {
+	for _, f := range files {
+		name := fileName(f)
+
+		status, err := rmt.EnsureFile(context.Background(), f)
+		if err != nil {
+			fmt.Printf("could not push file %s on %s with err=%v\n", f.RemotePath, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		state := f.State
+		if state == "" {
+			state = types.FileStateFile
+		}
+
+		report.Add(address, name, statusName(status), state)
+		if status == types.StatusEnforced {
+			h.notifyPath(f.RemotePath)
+		}
+	}
+}
+
+// lineName returns the name of l for the report
+func lineName(l types.Line) string {
+	if l.Regexp != "" {
+		return fmt.Sprintf("lines %s /%s/", l.Path, l.Regexp)
+	}
+
+	return fmt.Sprintf("lines %s %q", l.Path, l.Line)
+}
+
+// blockName returns the name of b for the report
+func blockName(b types.Block) string {
+	return "blocks " + b.Path
+}
+
+// editFiles ensures the lines and blocks on rmt, adds their outcome to the
+// report and notifies the services loading the changed files
+func editFiles(rmt target.Host, address string, lines []types.Line, blocks []types.Block, report *Report, h *handlers) {
+	for _, l := range lines {
+		status, err := rmt.EnsureLine(context.Background(), l)
+		addEdit(report, address, lineName(l), l.State, status, err)
+		if status == types.StatusEnforced {
+			h.notifyPath(l.Path)
+		}
+	}
+
+	for _, b := range blocks {
+		status, err := rmt.EnsureBlock(context.Background(), b)
+		addEdit(report, address, blockName(b), b.State, status, err)
+		if status == types.StatusEnforced {
+			h.notifyPath(b.Path)
+		}
+	}
+}
+
+// addEdit adds the outcome of a line or block edit to the report
+func addEdit(report *Report, address, name, state string, status types.StatusCode, err error) {
+	if err != nil {
+		fmt.Printf("could not edit %s on %s with err=%v\n", name, address, err)
+		report.Add(address, name, StatusFailed, err.Error())
+		return
+	}
+
+	if state == "" {
+		state = types.StatePresent
+	}
+
+	report.Add(address, name, statusName(status), state)
+}
@@ -0,0 +1,56 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// firewallRuleName returns the name of rule for the report and the conditions
+func firewallRuleName(rule types.FirewallRule) string {
+	action := rule.Action
+	if action == "" {
+		action = "allow"
+	}
+
+	parts := []string{"firewall", action, rule.Port + rule.App}
+	if rule.Proto != "" {
+		parts = append(parts, rule.Proto)
+	}
+
+	if rule.From != "" {
+		parts = append(parts, "from "+rule.From)
+	}
+
+	return strings.Join(append(parts, stateOf(rule.State)), " ")
+}
+
+// isEmptyFirewall checks if fw has no policies and no rules to apply
+func isEmptyFirewall(fw types.Firewall) bool {
+	return fw.Incoming == "" && fw.Outgoing == "" && len(fw.Rules) == 0
+}
+
+// ensureFirewall applies the firewall of the host and adds its outcome to the report
+func ensureFirewall(rmt target.Host, address string, fw types.Firewall, report *Report) {
+	if isEmptyFirewall(fw) {
+		return
+	}
+
+	backend := fw.Backend
+	if backend == "" {
+		backend = target.FirewallUFW
+	}
+	name := "firewall " + backend
+
+	status, err := rmt.EnsureFirewall(context.Background(), fw)
+	if err != nil {
+		fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+		report.Add(address, name, StatusFailed, err.Error())
+		return
+	}
+
+	report.Add(address, name, statusName(status), fmt.Sprintf("%d rules", len(fw.Rules)))
+}
@@ -0,0 +1,93 @@
+package bootstrap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// configDirs maps the config directories to the service loading them, changed
+// files in these directories notify the service
+var configDirs = map[string]string{
+	"/etc/apache2/": "apache2",
+	"/etc/nginx/":   "nginx",
+}
+
+// fpmDir matches the config directory of php-fpm with its version
+var fpmDir = regexp.MustCompile(`^/etc/php/([0-9]+\.[0-9]+)/fpm/`)
+
+// notification is a service to reload or restart
+type notification struct {
+	service string
+	state   string
+}
+
+// handlers collects the services to reload or restart because rules changed
+// their config, every service is handled once after all rules ran
+type handlers struct {
+	notified []notification
+}
+
+// notify adds service to the services to reload
+func (h *handlers) notify(service string) {
+	h.add(service, "reloaded")
+}
+
+// notifyRestart adds service to the services to restart, a restart replaces
+// a reload of the same service
+func (h *handlers) notifyRestart(service string) {
+	h.add(service, "restarted")
+}
+
+// notifyPath notifies the service loading the config at remotePath, if any
+func (h *handlers) notifyPath(remotePath string) {
+	for dir, service := range configDirs {
+		if strings.HasPrefix(remotePath, dir) {
+			h.notify(service)
+		}
+	}
+
+	if m := fpmDir.FindStringSubmatch(remotePath); m != nil {
+		h.notify("php" + m[1] + "-fpm")
+	}
+}
+
+func (h *handlers) add(service, state string) {
+	if service == "" {
+		return
+	}
+
+	for i, n := range h.notified {
+		if n.service == service {
+			if state == "restarted" {
+				h.notified[i].state = state
+			}
+			return
+		}
+	}
+
+	h.notified = append(h.notified, notification{service: service, state: state})
+}
+
+// run reloads or restarts the notified services on rmt and adds their outcome
+// to the report
+func (h *handlers) run(rmt target.Host, address string, report *Report) {
+	for _, n := range h.notified {
+		name := strings.TrimSuffix(n.state, "ed") + " " + n.service
+
+		fmt.Printf("trying to %s on %s ...\n", name, address)
+		status, err := rmt.EnsureService(types.Service{Name: n.service, State: n.state})
+		if err != nil {
+			fmt.Printf("could not %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "notified")
+	}
+
+	h.notified = nil
+}
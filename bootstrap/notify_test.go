@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"testing"
+)
+
+func TestHandlers(t *testing.T) {
+	h := handlers{}
+	h.notify("apache2")
+	h.notify("")
+	h.notify("php8.1-fpm")
+	h.notifyPath("/etc/apache2/sites-available/000-default.conf")
+	h.notifyPath("/var/www/html/index.php")
+
+	expected := []notification{{"apache2", "reloaded"}, {"php8.1-fpm", "reloaded"}}
+	if len(h.notified) != 2 || h.notified[0] != expected[0] || h.notified[1] != expected[1] {
+		t.Errorf("expected %v and got %v", expected, h.notified)
+	}
+
+	h.notifyPath("/etc/php/8.1/fpm/pool.d/www.conf")
+	if len(h.notified) != 2 {
+		t.Errorf("expected %v and got %v", 2, len(h.notified))
+	}
+
+	h.notifyRestart("apache2")
+	h.notify("apache2")
+	if h.notified[0].state != "restarted" {
+		t.Errorf("expected %v and got %v", "restarted", h.notified[0].state)
+	}
+}
@@ -0,0 +1,45 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// phpIniName returns the name of p for the report
+func phpIniName(p types.PHPIni) string {
+	if p.Path != "" {
+		return "php_ini " + p.Path
+	}
+
+	sapi := p.SAPI
+	if sapi == "" {
+		sapi = target.SAPIApache2
+	}
+
+	if p.Version == "" {
+		return "php_ini " + sapi
+	}
+
+	return fmt.Sprintf("php_ini %s %s", p.Version, sapi)
+}
+
+// ensurePHPIni sets the php.ini values on rmt, adds their outcome to the report
+// and notifies the services loading the changed php.ini
+func ensurePHPIni(rmt target.Host, address string, inis []types.PHPIni, report *Report, h *handlers) {
+	for _, p := range inis {
+		name := phpIniName(p)
+
+		status, service, err := rmt.EnsurePHPIni(context.Background(), p)
+		if err != nil {
+			fmt.Printf("could not set %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), fmt.Sprintf("%d settings", len(p.Settings)))
+		h.notify(service)
+	}
+}
@@ -0,0 +1,62 @@
+package bootstrap
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter writes complete lines to out with the prefix of the host, so the
+// output of hosts running in parallel is not interleaved within a line
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+
+	// mu is shared by the writers of all hosts writing to out
+	mu  *sync.Mutex
+	buf bytes.Buffer
+}
+
+// newPrefixWriter returns a writer prefixing the lines with [host]
+func newPrefixWriter(out io.Writer, host string, mu *sync.Mutex) *prefixWriter {
+	return &prefixWriter{
+		out:    out,
+		prefix: "[" + host + "] ",
+		mu:     mu,
+	}
+}
+
+// Write buffers p and writes the complete lines to out
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		err := w.writeLine(w.buf.Next(i + 1))
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes the last line when it's not terminated by a newline
+func (w *prefixWriter) Flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	line := append(w.buf.Next(w.buf.Len()), '\n')
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err := io.WriteString(w.out, w.prefix+string(line))
+	return err
+}
@@ -0,0 +1,34 @@
+package bootstrap
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+func TestPrefixWriter(t *testing.T) {
+	out := bytes.Buffer{}
+	mu := sync.Mutex{}
+	w := newPrefixWriter(&out, "127.0.0.1", &mu)
+
+	w.Write([]byte("Reading package "))
+	w.Write([]byte("lists...\nBuilding"))
+
+	expected := "[127.0.0.1] Reading package lists...\n"
+	if out.String() != expected {
+		t.Errorf("expected %q and got %q", expected, out.String())
+	}
+
+	w.Write([]byte(" dependency tree\nDone"))
+	w.Flush()
+
+	expected += "[127.0.0.1] Building dependency tree\n[127.0.0.1] Done\n"
+	if out.String() != expected {
+		t.Errorf("expected %q and got %q", expected, out.String())
+	}
+
+	w.Flush()
+	if out.String() != expected {
+		t.Errorf("expected %q and got %q", expected, out.String())
+	}
+}
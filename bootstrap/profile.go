@@ -0,0 +1,259 @@
+package bootstrap
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// profiles holds the built-in stack profiles, every profile is a directory with
+// a profile.yaml, the defaults of its vars in vars.yaml and the files it pushes
+//
+//go:embed profiles
+var profiles embed.FS
+
+// groupsFile is the file next to the defaults holding the groups by name
+const groupsFile = "groups.yaml"
+
+// Profiles returns the names of the built-in stack profiles
+func Profiles() []string {
+	entries, _ := fs.ReadDir(profiles, "profiles")
+
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	return names
+}
+
+// loadGroups reads the groups next to the default config, there are no groups
+// when the file does not exist
+func loadGroups(defaultpath string) (map[string]types.Group, error) {
+	groups := map[string]types.Group{}
+
+	groupsPath := filepath.Join(filepath.Dir(defaultpath), groupsFile)
+	content, err := os.ReadFile(groupsPath)
+	if os.IsNotExist(err) {
+		return groups, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "not able to read %s", groupsPath)
+	}
+
+	err = yaml.Unmarshal(content, &groups)
+	if err != nil {
+		return nil, errors.Wrapf(err, "groups file %s is corrupted", groupsPath)
+	}
+
+	return groups, nil
+}
+
+// applyGroups sets the vars and the profile of the groups of config, the host
+// wins over its groups and a group wins over the groups listed before it
+func applyGroups(config *types.Config, groups map[string]types.Group) error {
+	if config.Vars == nil {
+		config.Vars = map[string]string{}
+	}
+
+	vars := map[string]string{}
+	profile := ""
+	for _, name := range config.Groups {
+		group, ok := groups[name]
+		if !ok {
+			return errors.Errorf("unknown group %s", name)
+		}
+
+		for k, v := range group.Vars {
+			vars[k] = v
+		}
+
+		if group.Profile != "" {
+			profile = group.Profile
+		}
+	}
+
+	for k, v := range vars {
+		if _, ok := config.Vars[k]; !ok {
+			config.Vars[k] = v
+		}
+	}
+
+	if config.Profile == "" {
+		config.Profile = profile
+	}
+
+	return nil
+}
+
+// loadProfile renders the rules of the profile with the host vars. The
+// defaults of the profile are added to vars when they are not set. Files of
+// the profile are added as inline content.
+func loadProfile(name string, address string, vars map[string]string) (types.Config, error) {
+	var profile types.Config
+
+	dir := path.Join("profiles", name)
+	if _, err := fs.Stat(profiles, path.Join(dir, "profile.yaml")); err != nil {
+		return profile, errors.Errorf("unknown profile %s, available profiles: %s", name, strings.Join(Profiles(), ", "))
+	}
+
+	defaults := map[string]string{}
+	content, err := profiles.ReadFile(path.Join(dir, "vars.yaml"))
+	if err == nil {
+		err = yaml.Unmarshal(content, &defaults)
+		if err != nil {
+			return profile, errors.Wrapf(err, "vars of profile %s are corrupted", name)
+		}
+	}
+
+	for k, v := range defaults {
+		if _, ok := vars[k]; !ok {
+			vars[k] = v
+		}
+	}
+
+	content, err = profiles.ReadFile(path.Join(dir, "profile.yaml"))
+	if err != nil {
+		return profile, errors.Wrapf(err, "unable to read profile %s", name)
+	}
+
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return profile, errors.Wrapf(err, "unable to parse profile %s", name)
+	}
+
+	var out bytes.Buffer
+	err = tmpl.Execute(&out, TemplateData{Address: address, Vars: vars})
+	if err != nil {
+		return profile, errors.Wrapf(err, "unable to render profile %s", name)
+	}
+
+	err = yaml.Unmarshal(out.Bytes(), &profile)
+	if err != nil {
+		return profile, errors.Wrapf(err, "profile %s is corrupted", name)
+	}
+
+	for i, f := range profile.Files {
+		if f.LocalPath == "" {
+			continue
+		}
+
+		fileContent, err := profiles.ReadFile(path.Join(dir, f.LocalPath))
+		if err != nil {
+			return profile, errors.Wrapf(err, "unable to read %s of profile %s", f.LocalPath, name)
+		}
+
+		profile.Files[i].Content = string(fileContent)
+		profile.Files[i].LocalPath = ""
+	}
+
+	return profile, nil
+}
+
+// mergeApacheItems appends the items of src which config does not declare, so
+// the state of config wins
+func mergeApacheItems(items, src []types.ApacheItem) []types.ApacheItem {
+	declared := map[string]bool{}
+	for _, item := range items {
+		declared[item.Name] = true
+	}
+
+	for _, item := range src {
+		if !declared[item.Name] {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// mergeServices appends the services of src which config does not declare
+func mergeServices(services, src []types.Service) []types.Service {
+	declared := map[string]bool{}
+	for _, service := range services {
+		declared[service.Name] = true
+	}
+
+	for _, service := range src {
+		if !declared[service.Name] {
+			services = append(services, service)
+		}
+	}
+
+	return services
+}
+
+// mergeFiles appends the files of src whose remote path config does not declare
+func mergeFiles(files, src []types.File) []types.File {
+	declared := map[string]bool{}
+	for _, f := range files {
+		declared[f.RemotePath] = true
+	}
+
+	for _, f := range src {
+		if !declared[f.RemotePath] {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+// appendRules appends the rules of src to the rules of config, the timezone and
+// the firewall settings of src are used when config has none. Apache items,
+// services and files which config already declares are not appended, so the
+// host wins over its profile and the defaults.
+func appendRules(config *types.Config, src types.Config) {
+	if config.Timezone == "" {
+		config.Timezone = src.Timezone
+	}
+
+	if config.Firewall.Backend == "" {
+		config.Firewall.Backend = src.Firewall.Backend
+	}
+
+	if config.Firewall.Incoming == "" {
+		config.Firewall.Incoming = src.Firewall.Incoming
+	}
+
+	if config.Firewall.Outgoing == "" {
+		config.Firewall.Outgoing = src.Firewall.Outgoing
+	}
+
+	config.Install = append(config.Install, src.Install...)
+	config.Remove = append(config.Remove, src.Remove...)
+	config.Run = append(config.Run, src.Run...)
+	config.Restart = append(config.Restart, src.Restart...)
+	config.Files = mergeFiles(config.Files, src.Files)
+	config.Certificates = append(config.Certificates, src.Certificates...)
+	config.Sync = append(config.Sync, src.Sync...)
+	config.Lines = append(config.Lines, src.Lines...)
+	config.Blocks = append(config.Blocks, src.Blocks...)
+	config.PHPIni = append(config.PHPIni, src.PHPIni...)
+	config.Apache.Modules = mergeApacheItems(config.Apache.Modules, src.Apache.Modules)
+	config.Apache.Confs = mergeApacheItems(config.Apache.Confs, src.Apache.Confs)
+	config.Apache.Sites = mergeApacheItems(config.Apache.Sites, src.Apache.Sites)
+	config.Firewall.Rules = append(config.Firewall.Rules, src.Firewall.Rules...)
+	config.Sysctl = append(config.Sysctl, src.Sysctl...)
+	config.Cron = append(config.Cron, src.Cron...)
+	config.UserGroups = append(config.UserGroups, src.UserGroups...)
+	config.Users = append(config.Users, src.Users...)
+	config.AuthorizedKeys = append(config.AuthorizedKeys, src.AuthorizedKeys...)
+	config.Services = mergeServices(config.Services, src.Services)
+	config.Exec = append(config.Exec, src.Exec...)
+	config.Scripts = append(config.Scripts, src.Scripts...)
+	config.Fetch = append(config.Fetch, src.Fetch...)
+	config.Repositories = append(config.Repositories, src.Repositories...)
+}
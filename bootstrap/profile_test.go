@@ -0,0 +1,137 @@
+package bootstrap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+// packageNames joins the names of pkgs
+func packageNames(pkgs types.Packages) string {
+	names := []string{}
+	for _, p := range pkgs {
+		names = append(names, p.Name)
+	}
+
+	return strings.Join(names, ",")
+}
+
+func TestLoadProfile(t *testing.T) {
+	vars := map[string]string{"php_version": "8.2", "fpm_max_children": "20"}
+	profile, err := loadProfile("nginx-fpm", "127.0.0.1", vars)
+	if err != nil {
+		t.Fatalf("expected %v and got %v", nil, err)
+	}
+
+	if vars["fpm_pm"] != "dynamic" {
+		t.Errorf("expected %v and got %v", "dynamic", vars["fpm_pm"])
+	}
+
+	if len(profile.Services) != 2 || profile.Services[0].Name != "php8.2-fpm" {
+		t.Errorf("expected %v and got %v", "php8.2-fpm", profile.Services)
+	}
+
+	if len(profile.Files) != 2 {
+		t.Fatalf("expected %v and got %v", 2, len(profile.Files))
+	}
+
+	pool := profile.Files[1]
+	if pool.RemotePath != "/etc/php/8.2/fpm/pool.d/www.conf" {
+		t.Errorf("expected %v and got %v", "/etc/php/8.2/fpm/pool.d/www.conf", pool.RemotePath)
+	}
+
+	if pool.LocalPath != "" || !strings.Contains(pool.Content, "pm.max_children = {{ .Vars.fpm_max_children }}") {
+		t.Errorf("expected %v and got %v", "inline pool template", pool.Content)
+	}
+
+	profile, err = loadProfile("apache-modphp", "127.0.0.1", map[string]string{})
+	if err != nil {
+		t.Fatalf("expected %v and got %v", nil, err)
+	}
+
+	if packageNames(profile.Install) != "apache2,php,libapache2-mod-php" || len(profile.Apache.Modules) != 0 {
+		t.Errorf("expected %v and got %v %v", "the distro metapackages", profile.Install, profile.Apache.Modules)
+	}
+
+	profile, err = loadProfile("apache-modphp", "127.0.0.1", map[string]string{"php_version": "8.2"})
+	if err != nil {
+		t.Fatalf("expected %v and got %v", nil, err)
+	}
+
+	if packageNames(profile.Install) != "apache2,php8.2,libapache2-mod-php8.2" || len(profile.Apache.Modules) != 1 {
+		t.Errorf("expected %v and got %v %v", "php8.2", profile.Install, profile.Apache.Modules)
+	}
+
+	_, err = loadProfile("lamp", "127.0.0.1", map[string]string{})
+	if err == nil {
+		t.Errorf("expected an error for an unknown profile")
+	}
+}
+
+func TestApplyGroups(t *testing.T) {
+	groups := map[string]types.Group{
+		"web":  {Profile: "apache-modphp", Vars: map[string]string{"php_version": "8.1", "env": "prod"}},
+		"fpm":  {Profile: "nginx-fpm", Vars: map[string]string{"php_version": "8.2"}},
+		"none": {},
+	}
+
+	config := types.Config{Groups: []string{"web", "fpm", "none"}, Vars: map[string]string{"env": "staging"}}
+	err := applyGroups(&config, groups)
+	if err != nil {
+		t.Fatalf("expected %v and got %v", nil, err)
+	}
+
+	if config.Profile != "nginx-fpm" {
+		t.Errorf("expected %v and got %v", "nginx-fpm", config.Profile)
+	}
+
+	if config.Vars["php_version"] != "8.2" || config.Vars["env"] != "staging" {
+		t.Errorf("expected %v and got %v", "8.2 staging", config.Vars)
+	}
+
+	config = types.Config{Groups: []string{"db"}}
+	err = applyGroups(&config, groups)
+	if err == nil {
+		t.Errorf("expected an error for an unknown group")
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	names := Profiles()
+	if strings.Join(names, ",") != "apache-modphp,nginx-fpm" {
+		t.Errorf("expected %v and got %v", "apache-modphp,nginx-fpm", names)
+	}
+}
+
+func TestAppendRules(t *testing.T) {
+	config := types.Config{
+		Apache:   types.Apache{Sites: []types.ApacheItem{{Name: "000-default", State: "disabled"}}},
+		Services: []types.Service{{Name: "apache2", State: "stopped"}},
+		Files:    []types.File{{RemotePath: "/var/www/html/index.php", Content: "<?php"}},
+	}
+
+	profile, err := loadProfile("apache-modphp", "127.0.0.1", map[string]string{})
+	if err != nil {
+		t.Fatalf("expected %v and got %v", nil, err)
+	}
+
+	profile.Files = append(profile.Files, types.File{RemotePath: "/var/www/html/index.php", LocalPath: "index.php"})
+	appendRules(&config, profile)
+
+	if len(config.Apache.Sites) != 1 || config.Apache.Sites[0].State != "disabled" {
+		t.Errorf("expected %v and got %v", "the disabled site of the host", config.Apache.Sites)
+	}
+
+	if len(config.Services) != 1 || config.Services[0].State != "stopped" {
+		t.Errorf("expected %v and got %v", "the stopped service of the host", config.Services)
+	}
+
+	if len(config.Files) != 2 || config.Files[0].Content != "<?php" {
+		t.Errorf("expected %v and got %v", "the index.php of the host", config.Files)
+	}
+
+	if len(config.Install) != 3 {
+		t.Errorf("expected %v and got %v", 3, config.Install)
+	}
+}
@@ -0,0 +1,104 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// defaultRebootTimeout is the time to wait for a host to come back after a reboot
+const defaultRebootTimeout = 300 * time.Second
+
+var (
+	// rebootDelay gives the host time to go down, so the reconnect does not
+	// reach the sshd which is shutting down
+	rebootDelay = 10 * time.Second
+
+	// rebootPoll is the time between the connection attempts
+	rebootPoll = 5 * time.Second
+)
+
+// reconnect connects to the host of config until its boot id differs from
+// bootID or the timeout is reached. A host which still has the same boot id has
+// not gone down yet, or did not reboot at all.
+func reconnect(config types.Config, timeout time.Duration, bootID string) (target.Host, error) {
+	deadline := time.Now().Add(timeout)
+	time.Sleep(rebootDelay)
+
+	for {
+		rmt, err := connect(config)
+		if err == nil {
+			var id string
+			id, err = rmt.BootID(context.Background())
+			if err == nil && id != bootID {
+				return rmt, nil
+			}
+
+			rmt.Close()
+			if err == nil {
+				err = errors.New("the host has not rebooted")
+			}
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.Wrapf(err, "%s did not come back within %s", config.Host.Address, timeout)
+		}
+
+		fmt.Printf("waiting for %s to come back ...\n", config.Host.Address)
+		time.Sleep(rebootPoll)
+	}
+}
+
+// rebootIfRequired reboots the host of config when the reboot is enabled and
+// the host needs it. It returns the Remote to use for the remaining rules and
+// whether it is a new one.
+func rebootIfRequired(rmt target.Host, config types.Config, report *Report) (target.Host, bool, error) {
+	if !config.Reboot.IfRequired {
+		return rmt, false, nil
+	}
+
+	address := config.Host.Address
+	required, err := rmt.RebootRequired(context.Background())
+	if err != nil {
+		report.Add(address, "reboot", StatusFailed, err.Error())
+		return rmt, false, err
+	}
+
+	if !required {
+		report.Add(address, "reboot", StatusSatisfied, "not required")
+		return rmt, false, nil
+	}
+
+	bootID, err := rmt.BootID(context.Background())
+	if err != nil {
+		report.Add(address, "reboot", StatusFailed, err.Error())
+		return rmt, false, err
+	}
+
+	err = rmt.Reboot(context.Background())
+	if err != nil {
+		report.Add(address, "reboot", StatusFailed, err.Error())
+		return rmt, false, err
+	}
+
+	timeout := defaultRebootTimeout
+	if config.Reboot.Timeout > 0 {
+		timeout = time.Duration(config.Reboot.Timeout) * time.Second
+	}
+
+	start := time.Now()
+	rmt, err = reconnect(config, timeout, bootID)
+	if err != nil {
+		report.Add(address, "reboot", StatusFailed, err.Error())
+		return nil, false, err
+	}
+
+	took := time.Since(start).Round(time.Second)
+	fmt.Printf("%s is back after the reboot in %s\n", address, took)
+	report.Add(address, "reboot", StatusEnforced, "back in "+took.String())
+	return rmt, true, nil
+}
@@ -0,0 +1,38 @@
+package bootstrap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/slack/target/types"
+)
+
+// fastReboot shortens the reboot timings for the test and restores them after
+func fastReboot(t *testing.T) {
+	delay, poll := rebootDelay, rebootPoll
+	t.Cleanup(func() {
+		rebootDelay, rebootPoll = delay, poll
+	})
+
+	rebootDelay, rebootPoll = 0, 10*time.Millisecond
+}
+
+func TestReconnect(t *testing.T) {
+	fastReboot(t)
+
+	config := types.Config{Host: types.Host{Address: "127.0.0.1", Port: 1}}
+	start := time.Now()
+	_, err := reconnect(config, 50*time.Millisecond, "")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("expected the reconnect to give up after the timeout and got %v", time.Since(start))
+	}
+
+	rmt, rebooted, err := rebootIfRequired(nil, config, &Report{})
+	if err != nil || rebooted || rmt != nil {
+		t.Errorf("expected %v and got %v %v", "no reboot", rebooted, err)
+	}
+}
@@ -0,0 +1,97 @@
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/slack/target/types"
+)
+
+// the statuses of the report entries
+const (
+	StatusSkipped   = "skipped"
+	StatusUnknown   = "unknown"
+	StatusSatisfied = "satisfied"
+	StatusEnforced  = "enforced"
+	StatusFailed    = "failed"
+
+	// the statuses of certificates in the plan
+	StatusValid    = "valid"
+	StatusExpiring = "expiring"
+	StatusExpired  = "expired"
+)
+
+// Entry is the outcome of a rule on a host
+type Entry struct {
+	Host   string
+	Rule   string
+	Status string
+	Detail string
+
+	// Output is the stdout and stderr of commands
+	Output string
+}
+
+// statusName returns the report status of a rule status
+func statusName(status types.StatusCode) string {
+	switch status {
+	case types.StatusSatisfied:
+		return StatusSatisfied
+	case types.StatusEnforced:
+		return StatusEnforced
+	}
+
+	return StatusFailed
+}
+
+// Report collects the outcome of the rules for the summary of a plan or a run
+type Report struct {
+	Entries []Entry
+}
+
+// Add adds the outcome of rule on host
+func (r *Report) Add(host, rule, status, detail string) {
+	r.Entries = append(r.Entries, Entry{
+		Host:   host,
+		Rule:   rule,
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// AddOutput adds the outcome of rule on host with the output of its command
+func (r *Report) AddOutput(host, rule, status, detail string, res types.Response) {
+	r.Add(host, rule, status, detail)
+	r.Entries[len(r.Entries)-1].Output = strings.TrimSpace(res.Stdout.String() + "\n" + res.Stderr.String())
+}
+
+// Print prints the entries grouped by host in the order they were added
+func (r *Report) Print() {
+	if len(r.Entries) == 0 {
+		return
+	}
+
+	fmt.Println("report:")
+
+	hosts := []string{}
+	entries := map[string][]Entry{}
+	for _, e := range r.Entries {
+		if _, ok := entries[e.Host]; !ok {
+			hosts = append(hosts, e.Host)
+		}
+		entries[e.Host] = append(entries[e.Host], e)
+	}
+
+	for _, host := range hosts {
+		fmt.Printf("%s:\n", host)
+		for _, e := range entries[host] {
+			fmt.Printf("  %-9s %s (%s)\n", e.Status, e.Rule, e.Detail)
+			if e.Output != "" {
+				for _, line := range strings.Split(e.Output, "\n") {
+					fmt.Printf("            | %s\n", line)
+				}
+			}
+		}
+	}
+	fmt.Println("----------------")
+}
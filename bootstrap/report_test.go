@@ -0,0 +1,38 @@
+package bootstrap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestReport(t *testing.T) {
+	cases := map[types.StatusCode]string{
+		types.StatusSatisfied: StatusSatisfied,
+		types.StatusEnforced:  StatusEnforced,
+		types.StatusFailed:    StatusFailed,
+	}
+
+	for status, expected := range cases {
+		if statusName(status) != expected {
+			t.Errorf("expected %v and got %v", expected, statusName(status))
+		}
+	}
+
+	r := Report{}
+	r.Add("127.0.0.1", "install php", StatusSkipped, "when vars.php")
+	r.AddOutput("127.0.0.1", "exec migrate", StatusEnforced, "exit 0", types.Response{
+		Stdout: *bytes.NewBufferString("Nothing to migrate.\n"),
+	})
+
+	if len(r.Entries) != 2 {
+		t.Fatalf("expected %v and got %v", 2, len(r.Entries))
+	}
+
+	if r.Entries[1].Output != "Nothing to migrate." {
+		t.Errorf("expected %v and got %v", "Nothing to migrate.", r.Entries[1].Output)
+	}
+
+	r.Print()
+}
@@ -0,0 +1,31 @@
+package bootstrap
+
+import (
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// serviceName returns the name of s for the report
+func serviceName(s types.Service) string {
+	return "services " + s.Name
+}
+
+// ensureServices ensures the state of the services on rmt and adds their
+// outcome to the report
+func ensureServices(rmt target.Host, address string, services []types.Service, report *Report) {
+	for _, s := range services {
+		name := serviceName(s)
+		fmt.Printf("trying to ensure service %s on %s ...\n", s.Name, address)
+
+		status, err := rmt.EnsureService(s)
+		if err != nil {
+			fmt.Printf("could not ensure service %s on %s with err=%v\n", s.Name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), s.State)
+	}
+}
@@ -0,0 +1,30 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// syncName returns the name of s for the report
+func syncName(s types.Sync) string {
+	return fmt.Sprintf("sync %s", s.RemotePath)
+}
+
+// syncDirs syncs the directories to rmt and adds their outcome to the report
+func syncDirs(rmt target.Host, address string, syncs []types.Sync, report *Report) {
+	for _, s := range syncs {
+		name := syncName(s)
+
+		status, err := rmt.Sync(context.Background(), s)
+		if err != nil {
+			fmt.Printf("could not %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "from "+s.LocalPath)
+	}
+}
@@ -0,0 +1,77 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// sysctlName returns the name of s for the report
+func sysctlName(s types.Sysctl) string {
+	return fmt.Sprintf("sysctl %s=%s", s.Name, s.Value)
+}
+
+// cronName returns the name of c for the report
+func cronName(c types.Cron) string {
+	return fmt.Sprintf("cron %s %s", c.Name, stateOf(c.State))
+}
+
+// ensureSystem sets the hostname, the timezone and the kernel parameters on rmt
+// and adds their outcome to the report
+func ensureSystem(rmt target.Host, address string, config types.Config, report *Report) {
+	settings := []struct {
+		name   string
+		value  string
+		ensure func(context.Context, string) (types.StatusCode, error)
+	}{
+		{"hostname", config.Hostname, rmt.EnsureHostname},
+		{"timezone", config.Timezone, rmt.EnsureTimezone},
+	}
+
+	for _, s := range settings {
+		if s.value == "" {
+			continue
+		}
+
+		name := s.name + " " + s.value
+		status, err := s.ensure(context.Background(), s.value)
+		if err != nil {
+			fmt.Printf("could not set %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "")
+	}
+
+	for _, s := range config.Sysctl {
+		name := sysctlName(s)
+
+		status, err := rmt.EnsureSysctl(context.Background(), s)
+		if err != nil {
+			fmt.Printf("could not set %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "")
+	}
+}
+
+// ensureCron ensures the cron jobs on rmt and adds their outcome to the report
+func ensureCron(rmt target.Host, address string, crons []types.Cron, report *Report) {
+	for _, c := range crons {
+		name := cronName(c)
+
+		status, err := rmt.EnsureCron(context.Background(), c)
+		if err != nil {
+			fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), c.Schedule)
+	}
+}
@@ -0,0 +1,85 @@
+package bootstrap
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"text/template"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// TemplateData is passed to the templated files
+type TemplateData struct {
+	Address string
+	Facts   *types.Facts
+	Vars    map[string]string
+}
+
+// renderTemplates renders the templated files of config into the tmp dir and
+// returns the files to push, pointing to the rendered files
+func renderTemplates(config types.Config, facts *types.Facts) ([]types.File, error) {
+	data := TemplateData{
+		Address: config.Host.Address,
+		Facts:   facts,
+		Vars:    config.Vars,
+	}
+
+	dir := fmt.Sprintf("%s/%s", tmp, config.Host.Address)
+
+	files := make([]types.File, 0, len(config.Files))
+	for i, file := range config.Files {
+		if !file.Template {
+			files = append(files, file)
+			continue
+		}
+
+		// inline content is rendered in place
+		if file.LocalPath == "" {
+			tmpl, err := template.New(file.RemotePath).Option("missingkey=error").Parse(file.Content)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to parse template of %s", file.RemotePath)
+			}
+
+			var out bytes.Buffer
+			err = tmpl.Execute(&out, data)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to render template of %s", file.RemotePath)
+			}
+
+			file.Content = out.String()
+			files = append(files, file)
+			continue
+		}
+
+		tmpl, err := template.New(path.Base(file.LocalPath)).Option("missingkey=error").ParseFiles(file.LocalPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse template %s", file.LocalPath)
+		}
+
+		var out bytes.Buffer
+		err = tmpl.Execute(&out, data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to render template %s", file.LocalPath)
+		}
+
+		for _, d := range []string{tmp, dir} {
+			err = CheckDir(d)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		file.LocalPath = fmt.Sprintf("%s/%d-%s", dir, i, path.Base(file.LocalPath))
+		err = ioutil.WriteFile(file.LocalPath, out.Bytes(), 0644)
+		if err != nil {
+			return nil, errors.Wrapf(err, "writing rendered template %s", file.LocalPath)
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
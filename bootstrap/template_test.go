@@ -0,0 +1,67 @@
+package bootstrap
+
+import (
+	"os"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	defer os.RemoveAll(tmp)
+
+	config := types.Config{
+		Host: types.Host{
+			Address: "127.0.0.1",
+		},
+		Files: []types.File{
+			{
+				LocalPath:  "testdata/server/site.conf.tmpl",
+				RemotePath: "/etc/apache2/sites-available/site.conf",
+				Template:   true,
+			},
+			{
+				LocalPath:  "testdata/valid_defaults.yaml",
+				RemotePath: "/root/defaults.yaml",
+			},
+			{
+				Content:    "{{ .Facts.OS.ID }}\n",
+				RemotePath: "/etc/distro",
+				Template:   true,
+			},
+		},
+	}
+
+	facts := &types.Facts{
+		OS: types.OS{ID: "ubuntu", Version: "22.04"},
+	}
+
+	files, err := renderTemplates(config, facts)
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	if files[1].LocalPath != "testdata/valid_defaults.yaml" {
+		t.Errorf("expected %v and got %v", "testdata/valid_defaults.yaml", files[1].LocalPath)
+	}
+
+	content, err := os.ReadFile(files[0].LocalPath)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "ServerName 127.0.0.1\n# ubuntu 22.04\n"
+	if string(content) != expected {
+		t.Errorf("expected %v and got %v", expected, string(content))
+	}
+
+	if files[2].Content != "ubuntu\n" {
+		t.Errorf("expected %v and got %v", "ubuntu\n", files[2].Content)
+	}
+
+	config.Files[0].LocalPath = "testdata/missing.tmpl"
+	_, err = renderTemplates(config, facts)
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
@@ -0,0 +1,77 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/slack/target"
+	"github.com/slack/target/types"
+)
+
+// stateOf returns the state of a rule which is present by default
+func stateOf(state string) string {
+	if state == "" {
+		return types.StatePresent
+	}
+
+	return state
+}
+
+// userName returns the name of u for the report
+func userName(u types.User) string {
+	return fmt.Sprintf("user %s %s", u.Name, stateOf(u.State))
+}
+
+// userGroupName returns the name of g for the report
+func userGroupName(g types.UserGroup) string {
+	return fmt.Sprintf("user_group %s %s", g.Name, stateOf(g.State))
+}
+
+// authorizedKeyName returns the name of k for the report
+func authorizedKeyName(k types.AuthorizedKey) string {
+	return fmt.Sprintf("authorized_keys %s %s %s", k.User, k.LocalPath, stateOf(k.State))
+}
+
+// ensureUsers ensures the groups, the users and their authorized_keys on rmt
+// and adds their outcome to the report. Groups come first as users are
+// added to them.
+func ensureUsers(rmt target.Host, address string, config types.Config, report *Report) {
+	for _, g := range config.UserGroups {
+		name := userGroupName(g)
+
+		status, err := rmt.EnsureGroup(context.Background(), g)
+		if err != nil {
+			fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "")
+	}
+
+	for _, u := range config.Users {
+		name := userName(u)
+
+		status, err := rmt.EnsureUser(context.Background(), u)
+		if err != nil {
+			fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "")
+	}
+
+	for _, k := range config.AuthorizedKeys {
+		name := authorizedKeyName(k)
+
+		status, err := rmt.EnsureAuthorizedKey(context.Background(), k)
+		if err != nil {
+			fmt.Printf("could not ensure %s on %s with err=%v\n", name, address, err)
+			report.Add(address, name, StatusFailed, err.Error())
+			continue
+		}
+
+		report.Add(address, name, statusName(status), "")
+	}
+}
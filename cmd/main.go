@@ -2,18 +2,30 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/slack/bootstrap"
+	"github.com/slack/target/types"
 )
 
 const (
 	DefaultPHPServerConfig = "defaults.yaml"
 	ConfigDir              = "config"
 
+	// FactsCmd prints the facts of all hosts as JSON
+	FactsCmd = "facts"
+
+	// ExecCmd runs a command on all or a subset of the hosts
+	ExecCmd = "exec"
+
+	// FetchCmd downloads files from all or a subset of the hosts
+	FetchCmd = "fetch"
+
 	y   = "y"
 	yes = "yes"
 	n   = "n"
@@ -21,7 +33,22 @@ const (
 )
 
 func main() {
-	b := bootstrap.Client{}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case FactsCmd:
+			facts()
+		case ExecCmd:
+			os.Exit(execute(os.Args[2:]))
+		case FetchCmd:
+			os.Exit(fetch(os.Args[2:]))
+		default:
+			fmt.Printf("unknown command %s, available commands: %s, %s, %s\n", os.Args[1], FactsCmd, ExecCmd, FetchCmd)
+			os.Exit(1)
+		}
+		return
+	}
+
+	b := bootstrap.Client{Output: os.Stdout}
 	err := b.Run(ConfigDir, DefaultPHPServerConfig)
 	if err != nil {
 		panic(err)
@@ -52,3 +79,115 @@ func main() {
 		fmt.Printf("apply error %v\n", err)
 	}
 }
+
+// facts prints the facts of all hosts in the config dir
+func facts() {
+	b := bootstrap.Client{}
+	err := b.Load(ConfigDir, DefaultPHPServerConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	facts, err := b.Facts()
+	if err != nil {
+		panic(err)
+	}
+
+	factsBytes, err := json.MarshalIndent(facts, "", "  ")
+	if err != nil {
+		panic(errors.Wrap(err, "marshaling facts"))
+	}
+
+	fmt.Println(string(factsBytes))
+}
+
+// execute runs an ad-hoc command on the hosts and prints the output per host
+// it returns the exit code, which is 1 if the command failed on any host
+func execute(args []string) int {
+	fs := flag.NewFlagSet(ExecCmd, flag.ExitOnError)
+	hosts := fs.String("hosts", "", "comma separated glob patterns of the host addresses, all hosts if empty")
+	fs.Usage = func() {
+		fmt.Printf("usage: %s [-hosts pattern] command\n", ExecCmd)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cmd := strings.Join(fs.Args(), " ")
+	if cmd == "" {
+		fs.Usage()
+		return 1
+	}
+
+	// the output is streamed per host, so only the exit codes are printed at the end
+	b := bootstrap.Client{Output: os.Stdout}
+	err := b.Load(ConfigDir, DefaultPHPServerConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	results, err := b.Exec(cmd, *hosts)
+	if err != nil {
+		panic(err)
+	}
+
+	code := 0
+	for _, res := range results {
+		if res.Err != nil {
+			fmt.Printf("==> %s failed: %v\n", res.Address, res.Err)
+			code = 1
+			continue
+		}
+
+		fmt.Printf("==> %s exit %d\n", res.Address, res.Response.ExitStatus)
+
+		if !res.Success() {
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// fetch downloads the remote files from the hosts into a directory per host
+// it returns the exit code, which is 1 if any file could not be fetched
+func fetch(args []string) int {
+	fs := flag.NewFlagSet(FetchCmd, flag.ExitOnError)
+	hosts := fs.String("hosts", "", "comma separated glob patterns of the host addresses, all hosts if empty")
+	dest := fs.String("dest", "fetched", "local directory, the files are stored in <dest>/<host>/<remote path>")
+	fs.Usage = func() {
+		fmt.Printf("usage: %s [-hosts pattern] [-dest dir] remotepath...\n", FetchCmd)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	b := bootstrap.Client{}
+	err := b.Load(ConfigDir, DefaultPHPServerConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	results, err := b.Fetch(fs.Args(), *dest, *hosts)
+	if err != nil {
+		panic(err)
+	}
+
+	code := 0
+	for _, res := range results {
+		switch {
+		case res.Err != nil:
+			fmt.Printf("==> %s %s failed: %v\n", res.Address, res.RemotePath, res.Err)
+			code = 1
+		case res.Status == types.StatusSatisfied:
+			fmt.Printf("==> %s %s is unchanged in %s\n", res.Address, res.RemotePath, res.LocalPath)
+		default:
+			fmt.Printf("==> %s %s fetched to %s\n", res.Address, res.RemotePath, res.LocalPath)
+		}
+	}
+
+	return code
+}
@@ -1,13 +1,24 @@
 package internal
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
 	"log"
+	"math/big"
 	"net"
+	"os"
 	"os/user"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
@@ -135,11 +146,27 @@ func handleChannel(newChannel ssh.NewChannel) {
 				toWrite = u.Gid
 			}
 
+			// sha256sum of a file in the local filesystem
+			if strings.Contains(string(req.Payload), "sha256sum ") {
+				toWrite = sha256sum(string(req.Payload))
+			}
+
+			// every boot id is a new one, as if the target rebooted between the reads
+			if strings.Contains(string(req.Payload), "boot_id") {
+				toWrite = fmt.Sprintf("%d", time.Now().UnixNano())
+			}
+
+			// getent of the passwd and group databases with the current user
+			status := uint32(0)
+			if strings.Contains(string(req.Payload), "getent ") {
+				toWrite, status = getent(string(req.Payload), u)
+			}
+
 			if req.WantReply {
 				_ = req.Reply(true, []byte(toWrite))
 				channel.Write([]byte(toWrite))
 			}
-			channel.SendRequest("exit-status", false, ssh.Marshal(&exitStatusMsg{0}))
+			channel.SendRequest("exit-status", false, ssh.Marshal(&exitStatusMsg{status}))
 			channel.CloseWrite()
 			channel.Close()
 		default:
@@ -149,3 +176,120 @@ func handleChannel(newChannel ssh.NewChannel) {
 		}
 	}
 }
+
+// sha256sum returns the output of sha256sum for the quoted path at the end of the payload
+func sha256sum(payload string) string {
+	fields := strings.Fields(payload)
+	path := strings.Trim(fields[len(fields)-1], "'")
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), path)
+}
+
+// getent returns the entries of the current user and its group for the getent
+// payload, the exit status is 2 like getent when the key is not found
+func getent(payload string, u *user.User) (string, uint32) {
+	fields := strings.Fields(payload[strings.Index(payload, "getent "):])
+	if len(fields) < 2 {
+		return "", 1
+	}
+
+	name := u.Gid
+	if g, err := user.LookupGroupId(u.Gid); err == nil {
+		name = g.Name
+	}
+
+	entry := fmt.Sprintf("%s:x:%s:%s::%s:/bin/sh\n", u.Username, u.Uid, u.Gid, u.HomeDir)
+	keys := []string{u.Username, u.Uid}
+	if fields[1] == "group" {
+		entry = fmt.Sprintf("%s:x:%s:\n", name, u.Gid)
+		keys = []string{name, u.Gid}
+	}
+
+	if len(fields) < 3 {
+		return entry, 0
+	}
+
+	key := strings.Trim(fields[2], "'")
+	for _, k := range keys {
+		if k == key {
+			return entry, 0
+		}
+	}
+
+	return "", 2
+}
+
+// WriteTestCertificate writes a CA as chain, a certificate issued by the CA
+// expiring at notAfter and its key to dir and returns their paths
+func WriteTestCertificate(dir string, notAfter time.Time) (string, string, string, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "goconf test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	files := []struct {
+		name string
+		typ  string
+		der  []byte
+	}{
+		{"cert.pem", "CERTIFICATE", leafDER},
+		{"key.pem", "EC PRIVATE KEY", keyDER},
+		{"chain.pem", "CERTIFICATE", caDER},
+	}
+
+	paths := []string{}
+	for _, f := range files {
+		p := filepath.Join(dir, f.name)
+		err = os.WriteFile(p, pem.EncodeToMemory(&pem.Block{Type: f.typ, Bytes: f.der}), 0600)
+		if err != nil {
+			return "", "", "", err
+		}
+		paths = append(paths, p)
+	}
+
+	return paths[0], paths[1], paths[2], nil
+}
@@ -0,0 +1,96 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// the kinds of apache items with the flag of a2query and the suffix of the
+// a2en* and a2dis* tools
+const (
+	ApacheSite   = "site"
+	ApacheModule = "mod"
+	ApacheConf   = "conf"
+)
+
+var apacheQueryFlags = map[string]string{
+	ApacheSite:   "-s",
+	ApacheModule: "-m",
+	ApacheConf:   "-c",
+}
+
+// apacheService is the name of the apache service on debian based distributions
+const apacheService = "apache2"
+
+// apacheCmd returns the a2en* or a2dis* cmd enabling or disabling the item
+func apacheCmd(kind string, item types.ApacheItem) (string, error) {
+	if _, ok := apacheQueryFlags[kind]; !ok {
+		return "", fmt.Errorf("unknown apache kind %s", kind)
+	}
+
+	switch item.State {
+	case "", types.StateEnabled:
+		return fmt.Sprintf("sudo a2en%s -q %s", kind, shellQuote(item.Name)), nil
+	case types.StateDisabled:
+		return fmt.Sprintf("sudo a2dis%s -q %s", kind, shellQuote(item.Name)), nil
+	}
+
+	return "", fmt.Errorf("unknown state %s of %s %s", item.State, kind, item.Name)
+}
+
+// EnsureApache enables or disables an apache site, module or conf. apache2 has
+// to be reloaded when it's enforced.
+func (r *Remote) EnsureApache(ctx context.Context, kind string, item types.ApacheItem) (types.StatusCode, error) {
+	cmd, err := apacheCmd(kind, item)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	// a2query exits with 0 when the item is enabled
+	query := fmt.Sprintf("a2query %s %s", apacheQueryFlags[kind], shellQuote(item.Name))
+	res, err := r.run(query, bytes.NewBufferString(""))
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "could not query %s %s", kind, item.Name)
+	}
+
+	enabled := item.State != types.StateDisabled
+	if res.Success() == enabled {
+		return types.StatusSatisfied, nil
+	}
+
+	fmt.Printf("trying to %s on %s ...\n", strings.TrimPrefix(cmd, "sudo "), r.addr)
+
+	res, err = r.RunCmd(cmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not change %s %s: %v %s", kind, item.Name, err, res.Stderr.String())
+	}
+
+	return types.StatusEnforced, nil
+}
+
+// configTests holds the cmds validating the config of a service
+var configTests = map[string]string{
+	apacheService: "sudo apachectl configtest",
+	"nginx":       "sudo nginx -t",
+}
+
+// checkConfig validates the config of service before it's reloaded or
+// restarted, so a broken config does not take the service down
+func (r *Remote) checkConfig(service, action string) error {
+	cmd, ok := configTests[service]
+	if !ok || (action != "reload" && action != "restart") {
+		return nil
+	}
+
+	res, err := r.run(cmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return errors.Errorf("%s config test failed, %s aborted: %v %s", service, action, err, strings.TrimSpace(res.Stderr.String()))
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestApacheCmd(t *testing.T) {
+	cases := []struct {
+		kind     string
+		item     types.ApacheItem
+		expected string
+	}{
+		{ApacheSite, types.ApacheItem{Name: "000-default"}, "sudo a2ensite -q '000-default'"},
+		{ApacheModule, types.ApacheItem{Name: "rewrite", State: types.StateEnabled}, "sudo a2enmod -q 'rewrite'"},
+		{ApacheConf, types.ApacheItem{Name: "security", State: types.StateDisabled}, "sudo a2disconf -q 'security'"},
+	}
+
+	for _, c := range cases {
+		cmd, err := apacheCmd(c.kind, c.item)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if cmd != c.expected {
+			t.Errorf("expected %v and got %v", c.expected, cmd)
+		}
+	}
+
+	_, err := apacheCmd("vhost", types.ApacheItem{Name: "site"})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	_, err = apacheCmd(ApacheSite, types.ApacheItem{Name: "site", State: "missing"})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
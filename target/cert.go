@@ -0,0 +1,179 @@
+package target
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// CertificateFiles holds the validated PEM files of a certificate rule
+type CertificateFiles struct {
+	Cert  []byte
+	Key   []byte
+	Chain []byte
+
+	// Leaf is the parsed certificate, e.g. for its expiry
+	Leaf *x509.Certificate
+}
+
+// parseCerts parses all the certificates of a PEM file
+func parseCerts(content []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+
+	return certs, nil
+}
+
+// LoadCertificate reads the local files of c and validates them. The key has
+// to match the certificate and the certificate has to be signed by the first
+// certificate of the chain.
+func LoadCertificate(c types.Certificate) (*CertificateFiles, error) {
+	if c.Name == "" || c.Cert == "" || c.Key == "" {
+		return nil, errors.New("certificate needs a name, a cert and a key")
+	}
+
+	files := &CertificateFiles{}
+
+	var err error
+	files.Cert, err = os.ReadFile(c.Cert)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", c.Cert)
+	}
+
+	files.Key, err = os.ReadFile(c.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", c.Key)
+	}
+
+	pair, err := tls.X509KeyPair(files.Cert, files.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "key %s does not match certificate %s", c.Key, c.Cert)
+	}
+
+	files.Leaf, err = x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid certificate %s", c.Cert)
+	}
+
+	if c.Chain == "" {
+		return files, nil
+	}
+
+	files.Chain, err = os.ReadFile(c.Chain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", c.Chain)
+	}
+
+	chain, err := parseCerts(files.Chain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid chain %s", c.Chain)
+	}
+
+	err = files.Leaf.CheckSignatureFrom(chain[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "certificate %s is not issued by chain %s", c.Cert, c.Chain)
+	}
+
+	return files, nil
+}
+
+// certPaths returns the remote paths of the cert, the key and the chain
+func certPaths(c types.Certificate) (string, string, string) {
+	certPath, keyPath, chainPath := c.CertPath, c.KeyPath, c.ChainPath
+	if certPath == "" {
+		certPath = fmt.Sprintf("/etc/ssl/certs/%s.crt", c.Name)
+	}
+
+	if keyPath == "" {
+		keyPath = fmt.Sprintf("/etc/ssl/private/%s.key", c.Name)
+	}
+
+	if chainPath == "" {
+		chainPath = fmt.Sprintf("/etc/ssl/certs/%s.chain.crt", c.Name)
+	}
+
+	return certPath, keyPath, chainPath
+}
+
+// EnsureCertificate validates the files of c and pushes them owned by root.
+// The key is only readable by root, or by the group of c when it's set. An
+// expired certificate is not pushed.
+func (r *Remote) EnsureCertificate(ctx context.Context, c types.Certificate) (types.StatusCode, error) {
+	files, err := LoadCertificate(c)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if time.Now().After(files.Leaf.NotAfter) {
+		return types.StatusFailed, errors.Errorf("certificate %s expired on %s, refusing to push it", c.Cert, files.Leaf.NotAfter.UTC().Format("2006-01-02"))
+	}
+
+	certPath, keyPath, chainPath := certPaths(c)
+
+	keyMode, group := 0600, "root"
+	if c.Group != "" {
+		keyMode, group = 0640, c.Group
+	}
+
+	// the key is pushed first, so the certificate never points to a missing key
+	pushes := []types.File{
+		{RemotePath: keyPath, Content: string(files.Key), Mode: keyMode, Owner: "root", Group: group},
+		{RemotePath: certPath, Content: string(files.Cert), Mode: 0644, Owner: "root", Group: "root"},
+	}
+
+	if files.Chain != nil {
+		pushes = append(pushes, types.File{RemotePath: chainPath, Content: string(files.Chain), Mode: 0644, Owner: "root", Group: "root"})
+	}
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	status := types.StatusSatisfied
+	for _, f := range pushes {
+		err = client.MkdirAll(path.Dir(f.RemotePath))
+		if err != nil {
+			return types.StatusFailed, errors.Wrapf(err, "unable to create directory of %s", f.RemotePath)
+		}
+
+		s, err := r.EnsureFile(ctx, f)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		if s == types.StatusEnforced {
+			status = types.StatusEnforced
+		}
+	}
+
+	return status, nil
+}
@@ -0,0 +1,50 @@
+package target
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slack/internal"
+	"github.com/slack/target/types"
+)
+
+func TestLoadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cert, key, chain, err := internal.WriteTestCertificate(dir, time.Now().Add(90*24*time.Hour))
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	files, err := LoadCertificate(types.Certificate{Name: "site", Cert: cert, Key: key, Chain: chain})
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	if files.Leaf.Subject.CommonName != "localhost" || len(files.Chain) == 0 {
+		t.Errorf("expected %v and got %v", "localhost", files.Leaf.Subject.CommonName)
+	}
+
+	other, otherKey, _, err := internal.WriteTestCertificate(t.TempDir(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	for _, c := range []types.Certificate{
+		{Name: "site", Cert: cert, Key: otherKey},
+		{Name: "site", Cert: other, Key: otherKey, Chain: chain},
+		{Name: "site", Cert: cert, Key: key, Chain: key},
+		{Name: "site", Cert: cert, Key: filepath.Join(dir, "missing.pem")},
+		{Cert: cert, Key: key},
+	} {
+		_, err = LoadCertificate(c)
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", c)
+		}
+	}
+
+	certPath, keyPath, chainPath := certPaths(types.Certificate{Name: "site", KeyPath: "/etc/apache2/ssl/site.key"})
+	if certPath != "/etc/ssl/certs/site.crt" || keyPath != "/etc/apache2/ssl/site.key" || chainPath != "/etc/ssl/certs/site.chain.crt" {
+		t.Errorf("expected %v and got %v %v %v", "default paths", certPath, keyPath, chainPath)
+	}
+}
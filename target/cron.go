@@ -0,0 +1,109 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// cronDir is the directory of the cron files
+var cronDir = "/etc/cron.d"
+
+// cronName matches the names cron accepts for the files in cronDir
+var cronName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// cronFile returns the content of the cron file of c
+func cronFile(c types.Cron) (string, error) {
+	if !cronName.MatchString(c.Name) {
+		return "", fmt.Errorf("invalid cron name %q, only letters, digits, - and _ are allowed", c.Name)
+	}
+
+	schedule := strings.Fields(c.Schedule)
+	if !(len(schedule) == 5 || (len(schedule) == 1 && strings.HasPrefix(schedule[0], "@"))) {
+		return "", fmt.Errorf("invalid schedule %q of cron %s", c.Schedule, c.Name)
+	}
+
+	job := strings.TrimSpace(c.Job)
+	if job == "" || strings.Contains(job, "\n") {
+		return "", fmt.Errorf("cron %s needs a job of one line", c.Name)
+	}
+
+	user := c.User
+	if user == "" {
+		user = "root"
+	}
+
+	return fmt.Sprintf("# managed by goconf\n%s %s %s\n", strings.Join(schedule, " "), user, escapePercent(job)), nil
+}
+
+// escapePercent escapes the % of job which are not escaped yet, cron turns them
+// into newlines and passes the rest of the line as stdin otherwise
+func escapePercent(job string) string {
+	var b strings.Builder
+	escaped := false
+	for _, c := range job {
+		if c == '%' && !escaped {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+		escaped = c == '\\' && !escaped
+	}
+
+	return b.String()
+}
+
+// EnsureCron writes the cron file of the job, or removes it when the state is absent
+func (r *Remote) EnsureCron(ctx context.Context, c types.Cron) (types.StatusCode, error) {
+	p := path.Join(cronDir, c.Name)
+
+	switch c.State {
+	case "", types.StatePresent:
+	case types.StateAbsent:
+		if !cronName.MatchString(c.Name) {
+			return types.StatusFailed, fmt.Errorf("invalid cron name %q", c.Name)
+		}
+
+		sftp, err := r.sftpClient()
+		if err != nil {
+			return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+		}
+
+		err = sftp.Remove(p)
+		if os.IsNotExist(err) {
+			return types.StatusSatisfied, nil
+		}
+
+		if err != nil {
+			return types.StatusFailed, errors.Wrapf(err, "unable to remove %s", p)
+		}
+
+		fmt.Printf("%s is removed on %s\n", p, r.addr)
+		return types.StatusEnforced, nil
+	default:
+		return types.StatusFailed, fmt.Errorf("unknown state %s of cron %s", c.State, c.Name)
+	}
+
+	content, err := cronFile(c)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	// cron ignores files which are writable by others than the owner
+	written, err := r.writeFile(p, []byte(content), 0644)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !written {
+		return types.StatusSatisfied, nil
+	}
+
+	fmt.Printf("%s is updated on %s\n", p, r.addr)
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,227 @@
+package target
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// errDebCompression is returned when the control archive of a .deb can not be
+// read locally, the package info is then read by dpkg-deb on the target
+var errDebCompression = errors.New("unsupported control archive compression")
+
+// debUploadPath returns a unique remote path for the upload of a .deb, so hosts
+// applied in parallel and other users of the target do not collide
+func debUploadPath() (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate package name")
+	}
+
+	return fmt.Sprintf("/tmp/goconf-%s.deb", hex.EncodeToString(b)), nil
+}
+
+// debControl reads the package name and version from the control file of a
+// local .deb package
+func debControl(debPath string) (string, string, error) {
+	f, err := os.Open(debPath)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "unable to open %s", debPath)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != arMagic {
+		return "", "", fmt.Errorf("%s is not a .deb package", debPath)
+	}
+
+	header := make([]byte, arHeaderSize)
+	for {
+		_, err := io.ReadFull(r, header)
+		if err != nil {
+			return "", "", fmt.Errorf("%s has no control archive", debPath)
+		}
+
+		name := strings.TrimSuffix(strings.TrimSpace(string(header[0:16])), "/")
+		size, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "%s has a corrupted archive header", debPath)
+		}
+
+		entry := io.LimitReader(r, size)
+
+		switch name {
+		case "control.tar.gz":
+			gz, err := gzip.NewReader(entry)
+			if err != nil {
+				return "", "", errors.Wrapf(err, "unable to read control archive of %s", debPath)
+			}
+			return parseControlTar(gz)
+		case "control.tar":
+			return parseControlTar(entry)
+		}
+
+		if strings.HasPrefix(name, "control.tar") {
+			return "", "", errDebCompression
+		}
+
+		// ar entries are aligned to an even offset
+		if _, err := io.CopyN(io.Discard, r, size+size%2); err != nil {
+			return "", "", errors.Wrapf(err, "%s is corrupted", debPath)
+		}
+	}
+}
+
+// parseControlTar reads the control file from the control archive
+func parseControlTar(archive io.Reader) (string, string, error) {
+	tr := tar.NewReader(archive)
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			return "", "", errors.Wrap(err, "control file not found")
+		}
+
+		if path.Clean(h.Name) != "control" {
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return "", "", errors.Wrap(err, "unable to read control file")
+		}
+
+		return parseControl(string(content))
+	}
+}
+
+// parseControl reads the Package and Version fields of a control file
+func parseControl(content string) (string, string, error) {
+	var name, version string
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(line, "Package:") {
+			name = strings.TrimSpace(strings.TrimPrefix(line, "Package:"))
+		}
+
+		if strings.HasPrefix(line, "Version:") {
+			version = strings.TrimSpace(strings.TrimPrefix(line, "Version:"))
+		}
+	}
+
+	if name == "" || version == "" {
+		return "", "", errors.New("control file has no Package or Version")
+	}
+
+	return name, version, nil
+}
+
+// ensureDeb installs a local .deb package unless the same version is installed
+func (r *Remote) ensureDeb(ctx context.Context, pkg types.Package) (types.StatusCode, error) {
+	pm, err := r.packageManager()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	a, ok := pm.(apt)
+	if !ok {
+		return types.StatusFailed, fmt.Errorf("%s can not be installed with %s", pkg.Deb, pm.Name())
+	}
+
+	p := types.APT{
+		Status: types.StatusInstalled,
+		User:   r.activeUser,
+		Hold:   pkg.Hold,
+	}
+
+	p.Name, p.Version, err = debControl(pkg.Deb)
+	if err != nil && err != errDebCompression {
+		return types.StatusFailed, err
+	}
+
+	if err == nil {
+		ok, err := r.check(p)
+		if err != nil {
+			return types.StatusFailed, errors.Wrap(err, "ensure check failed")
+		}
+
+		if ok {
+			return r.ensurePackage(p)
+		}
+	}
+
+	remotePath, err := debUploadPath()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	err = r.Push(ctx, []types.File{
+		{
+			Owner:      r.connuser,
+			Mode:       0644,
+			LocalPath:  pkg.Deb,
+			RemotePath: remotePath,
+		},
+	})
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "could not push %s", pkg.Deb)
+	}
+	defer r.run("rm -f "+shellQuote(remotePath), bytes.NewBufferString(""))
+
+	// the control archive could not be read locally, so dpkg-deb reads it
+	if p.Name == "" {
+		res, err := r.run(fmt.Sprintf("dpkg-deb -f %s Package Version", shellQuote(remotePath)), bytes.NewBufferString(""))
+		if err != nil || !res.Success() {
+			return types.StatusFailed, errors.Errorf("could not read %s: %v %s", pkg.Deb, err, res.Stderr.String())
+		}
+
+		p.Name, p.Version, err = parseControl(res.Stdout.String())
+		if err != nil {
+			return types.StatusFailed, errors.Wrapf(err, "could not read %s", pkg.Deb)
+		}
+
+		ok, err := r.check(p)
+		if err != nil {
+			return types.StatusFailed, errors.Wrap(err, "ensure check failed")
+		}
+
+		if ok {
+			return r.ensurePackage(p)
+		}
+	}
+
+	// apt resolves the dependencies of the package
+	cmd := fmt.Sprintf("%s --allow-downgrades --allow-change-held-packages install %s", a.aptGet(), shellQuote(remotePath))
+	res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not install %s: %v %s", pkg.Deb, err, res.Stderr.String())
+	}
+
+	if p.Hold != nil {
+		_, err = r.hold(a, p.Name, *p.Hold)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+	}
+
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,46 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestDebControl(t *testing.T) {
+	name, version, err := debControl("testdata/php-hello_1.0.2-1_all.deb")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if name != "php-hello" {
+		t.Errorf("expected %v and got %v", "php-hello", name)
+	}
+
+	if version != "1.0.2-1" {
+		t.Errorf("expected %v and got %v", "1.0.2-1", version)
+	}
+
+	_, _, err = debControl("testdata/index.php")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	_, _, err = debControl("testdata/missing.deb")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
+
+func TestParseControl(t *testing.T) {
+	name, version, err := parseControl("Package: php-hello\nVersion: 1.0.2-1\n")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if name != "php-hello" || version != "1.0.2-1" {
+		t.Errorf("expected %v and got %v %v", "php-hello 1.0.2-1", name, version)
+	}
+
+	_, _, err = parseControl("Package: php-hello\n")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
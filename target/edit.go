@@ -0,0 +1,224 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// defaultMarker is the marker of blocks without a marker, {mark} is replaced
+// by BEGIN and END
+const defaultMarker = "# {mark} goconf managed block"
+
+// splitLines splits content into lines without the trailing newline
+func splitLines(content string) []string {
+	if content == "" {
+		return []string{}
+	}
+
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// joinLines joins the lines terminated by a newline
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// editLine returns content with the line of l ensured. The last line matching
+// the regexp is replaced, and the line is appended when nothing matches.
+func editLine(content string, l types.Line) (string, error) {
+	match := func(line string) bool {
+		return line == l.Line
+	}
+
+	if l.Regexp != "" {
+		re, err := regexp.Compile(l.Regexp)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid regexp %s", l.Regexp)
+		}
+		match = re.MatchString
+	}
+
+	lines := splitLines(content)
+
+	switch l.State {
+	case "", types.StatePresent:
+		if l.Line == "" {
+			return "", errors.New("line is empty")
+		}
+
+		last := -1
+		for i, line := range lines {
+			if match(line) || line == l.Line {
+				last = i
+			}
+		}
+
+		if last < 0 {
+			lines = append(lines, l.Line)
+		} else {
+			lines[last] = l.Line
+		}
+
+	case types.StateAbsent:
+		if l.Line == "" && l.Regexp == "" {
+			return "", errors.New("line and regexp are empty")
+		}
+
+		kept := []string{}
+		for _, line := range lines {
+			if !match(line) {
+				kept = append(kept, line)
+			}
+		}
+		lines = kept
+
+	default:
+		return "", fmt.Errorf("unknown state %s", l.State)
+	}
+
+	return joinLines(lines), nil
+}
+
+// editBlock returns content with the block of b ensured between its markers,
+// a new block is appended
+func editBlock(content string, b types.Block) (string, error) {
+	marker := b.Marker
+	if marker == "" {
+		marker = defaultMarker
+	}
+
+	if !strings.Contains(marker, "{mark}") {
+		return "", fmt.Errorf("marker %q has no {mark}", marker)
+	}
+
+	begin := strings.Replace(marker, "{mark}", "BEGIN", 1)
+	end := strings.Replace(marker, "{mark}", "END", 1)
+
+	lines := splitLines(content)
+
+	start, stop := -1, -1
+	for i, line := range lines {
+		if line == begin && start < 0 {
+			start = i
+		}
+
+		if line == end && start >= 0 {
+			stop = i
+			break
+		}
+	}
+
+	// appending another block would add one on every run
+	if start >= 0 && stop < 0 {
+		return "", fmt.Errorf("marker %q has no END line", begin)
+	}
+
+	before, after := lines, []string{}
+	if stop >= 0 {
+		before, after = lines[:start], lines[stop+1:]
+	}
+
+	edited := append([]string{}, before...)
+
+	switch b.State {
+	case "", types.StatePresent:
+		edited = append(edited, begin)
+		edited = append(edited, splitLines(b.Block)...)
+		edited = append(edited, end)
+	case types.StateAbsent:
+	default:
+		return "", fmt.Errorf("unknown state %s", b.State)
+	}
+
+	edited = append(edited, after...)
+	return joinLines(edited), nil
+}
+
+// editFile applies edit to the content of the remote file and replaces the file
+// when the content changed. A missing file is created when create is set and
+// the edit adds content.
+func (r *Remote) editFile(p string, create bool, edit func(string) (string, error)) (bool, error) {
+	current, err := r.readFile(p)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "unable to read file %s", p)
+	}
+	exists := err == nil
+
+	content, err := edit(string(current))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not edit %s", p)
+	}
+
+	if content == string(current) && (exists || content == "") {
+		return false, nil
+	}
+
+	if !exists && !create {
+		return false, fmt.Errorf("%s does not exist", p)
+	}
+
+	mode := os.FileMode(defaultFileMode)
+	if exists {
+		sftp, err := r.sftpClient()
+		if err != nil {
+			return false, errors.Wrap(err, "could not get sftp client")
+		}
+
+		st, err := sftp.Stat(p)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to stat %s", p)
+		}
+		mode = st.Mode().Perm()
+	}
+
+	err = r.replaceFile(p, []byte(content), mode)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// EnsureLine ensures a line is present in or absent from a remote file
+func (r *Remote) EnsureLine(ctx context.Context, l types.Line) (types.StatusCode, error) {
+	changed, err := r.editFile(l.Path, l.Create, func(content string) (string, error) {
+		return editLine(content, l)
+	})
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !changed {
+		return types.StatusSatisfied, nil
+	}
+
+	fmt.Printf("%s is edited on %s\n", l.Path, r.addr)
+	return types.StatusEnforced, nil
+}
+
+// EnsureBlock ensures a marked block is present in or absent from a remote file
+func (r *Remote) EnsureBlock(ctx context.Context, b types.Block) (types.StatusCode, error) {
+	changed, err := r.editFile(b.Path, b.Create, func(content string) (string, error) {
+		return editBlock(content, b)
+	})
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !changed {
+		return types.StatusSatisfied, nil
+	}
+
+	fmt.Printf("%s is edited on %s\n", b.Path, r.addr)
+	return types.StatusEnforced, nil
+}
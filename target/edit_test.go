@@ -0,0 +1,118 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestEditLine(t *testing.T) {
+	ini := "[PHP]\n;memory_limit = 128M\nmemory_limit = 128M\nupload_max_filesize = 2M\n"
+
+	cases := []struct {
+		line     types.Line
+		content  string
+		expected string
+	}{
+		{
+			line:     types.Line{Regexp: "^memory_limit", Line: "memory_limit = 256M"},
+			content:  ini,
+			expected: "[PHP]\n;memory_limit = 128M\nmemory_limit = 256M\nupload_max_filesize = 2M\n",
+		},
+		{
+			line:     types.Line{Regexp: "^post_max_size", Line: "post_max_size = 8M"},
+			content:  ini,
+			expected: ini + "post_max_size = 8M\n",
+		},
+		{
+			line:     types.Line{Line: "upload_max_filesize = 2M"},
+			content:  ini,
+			expected: ini,
+		},
+		{
+			line:     types.Line{Regexp: "memory_limit", State: types.StateAbsent},
+			content:  ini,
+			expected: "[PHP]\nupload_max_filesize = 2M\n",
+		},
+		{
+			line:     types.Line{Line: "127.0.1.1 web1"},
+			content:  "127.0.0.1 localhost",
+			expected: "127.0.0.1 localhost\n127.0.1.1 web1\n",
+		},
+		{
+			line:     types.Line{Line: "127.0.1.1 web1"},
+			content:  "",
+			expected: "127.0.1.1 web1\n",
+		},
+	}
+
+	for _, c := range cases {
+		content, err := editLine(c.content, c.line)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if content != c.expected {
+			t.Errorf("expected %q and got %q", c.expected, content)
+		}
+	}
+
+	for _, l := range []types.Line{{Regexp: "("}, {State: types.StateAbsent}, {Line: "x", State: "missing"}, {}} {
+		_, err := editLine(ini, l)
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", l)
+		}
+	}
+}
+
+func TestEditBlock(t *testing.T) {
+	hosts := "127.0.0.1 localhost\n"
+	block := types.Block{Block: "10.0.1.5 db\n10.0.1.6 cache"}
+
+	content, err := editBlock(hosts, block)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "127.0.0.1 localhost\n# BEGIN goconf managed block\n10.0.1.5 db\n10.0.1.6 cache\n# END goconf managed block\n"
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	block.Block = "10.0.1.7 db"
+	content, err = editBlock(content+"::1 localhost\n", block)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected = "127.0.0.1 localhost\n# BEGIN goconf managed block\n10.0.1.7 db\n# END goconf managed block\n::1 localhost\n"
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	block.State = types.StateAbsent
+	content, err = editBlock(content, block)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if content != "127.0.0.1 localhost\n::1 localhost\n" {
+		t.Errorf("expected %q and got %q", "127.0.0.1 localhost\n::1 localhost\n", content)
+	}
+
+	content, err = editBlock("[PHP]\n", types.Block{Block: "opcache.enable=1", Marker: "; {mark} opcache"})
+	if err != nil || content != "[PHP]\n; BEGIN opcache\nopcache.enable=1\n; END opcache\n" {
+		t.Errorf("expected %q and got %q", "[PHP]\n; BEGIN opcache\nopcache.enable=1\n; END opcache\n", content)
+	}
+
+	_, err = editBlock(hosts, types.Block{Marker: "# goconf"})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	// a BEGIN line without its END line is not completed with another block
+	_, err = editBlock(hosts+"# BEGIN goconf managed block\n10.0.0.1 db\n", block)
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
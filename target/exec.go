@@ -0,0 +1,116 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// shellQuote quotes s as a single word for sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commandLine returns the cmd running cmd in dir with env as user
+// dir, env and user are optional
+func commandLine(cmd, dir string, env map[string]string, user string) string {
+	if dir != "" {
+		cmd = fmt.Sprintf("cd %s && %s", shellQuote(dir), cmd)
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	line := []string{}
+	if user != "" {
+		line = append(line, "sudo", "-u", shellQuote(user), "-H")
+	}
+
+	line = append(line, "env")
+	for _, k := range keys {
+		line = append(line, shellQuote(k+"="+env[k]))
+	}
+
+	line = append(line, "sh", "-c", shellQuote(cmd))
+	return strings.Join(line, " ")
+}
+
+// guarded checks the guards in the same dir, env and user as the command
+// it returns true if a guard short-circuits the command
+func (r *Remote) guarded(g types.Guards, dir string, env map[string]string, user string) (bool, string, error) {
+	if g.Creates != "" {
+		res, err := r.run(commandLine("test -e "+shellQuote(g.Creates), dir, env, user), bytes.NewBufferString(""))
+		if err != nil {
+			return false, "", errors.Wrap(err, "could not check creates")
+		}
+
+		if res.Success() {
+			return true, fmt.Sprintf("%s exists", g.Creates), nil
+		}
+	}
+
+	if g.Unless != "" {
+		res, err := r.run(commandLine(g.Unless, dir, env, user), bytes.NewBufferString(""))
+		if err != nil {
+			return false, "", errors.Wrap(err, "could not run unless")
+		}
+
+		if res.Success() {
+			return true, fmt.Sprintf("unless %q succeeded", g.Unless), nil
+		}
+	}
+
+	if g.OnlyIf != "" {
+		res, err := r.run(commandLine(g.OnlyIf, dir, env, user), bytes.NewBufferString(""))
+		if err != nil {
+			return false, "", errors.Wrap(err, "could not run onlyif")
+		}
+
+		if !res.Success() {
+			return true, fmt.Sprintf("onlyif %q failed", g.OnlyIf), nil
+		}
+	}
+
+	return false, "", nil
+}
+
+// Exec runs the command unless a guard short-circuits it, it returns the
+// response of the command
+func (r *Remote) Exec(ctx context.Context, c types.Command) (types.StatusCode, types.Response, error) {
+	if strings.TrimSpace(c.Command) == "" {
+		return types.StatusFailed, types.Response{}, errors.New("command is empty")
+	}
+
+	skip, reason, err := r.guarded(c.Guards, c.Dir, c.Env, c.User)
+	if err != nil {
+		return types.StatusFailed, types.Response{}, err
+	}
+
+	if skip {
+		res := types.Response{}
+		res.Stdout.WriteString(reason)
+		return types.StatusSatisfied, res, nil
+	}
+
+	// the command may add users and groups
+	defer r.resetIDs()
+
+	res, err := r.RunCmd(commandLine(c.Command, c.Dir, c.Env, c.User), bytes.NewBufferString(""))
+	if err != nil {
+		return types.StatusFailed, res, errors.Wrapf(err, "could not run %s", c.Command)
+	}
+
+	if !res.Success() {
+		return types.StatusFailed, res, fmt.Errorf("%s exited with %d", c.Command, res.ExitStatus)
+	}
+
+	return types.StatusEnforced, res, nil
+}
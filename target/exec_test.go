@@ -0,0 +1,33 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	cases := map[string]string{
+		"composer install": `'composer install'`,
+		"it's":             `'it'\''s'`,
+		"":                 `''`,
+	}
+
+	for s, expected := range cases {
+		if shellQuote(s) != expected {
+			t.Errorf("expected %v and got %v", expected, shellQuote(s))
+		}
+	}
+}
+
+func TestCommandLine(t *testing.T) {
+	cmd := commandLine("php artisan migrate --force", "", nil, "")
+	expected := `env sh -c 'php artisan migrate --force'`
+	if cmd != expected {
+		t.Errorf("expected %v and got %v", expected, cmd)
+	}
+
+	cmd = commandLine("composer install", "/var/www/app", map[string]string{"COMPOSER_HOME": "/tmp", "APP_ENV": "prod"}, "www-data")
+	expected = `sudo -u 'www-data' -H env 'APP_ENV=prod' 'COMPOSER_HOME=/tmp' sh -c 'cd '\''/var/www/app'\'' && composer install'`
+	if cmd != expected {
+		t.Errorf("expected %v and got %v", expected, cmd)
+	}
+}
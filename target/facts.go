@@ -0,0 +1,146 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// factsMarker starts a section in the output of factsScript
+const factsMarker = "### goconf:"
+
+// factsScript collects all facts in a single round-trip, every section is
+// started by factsMarker and the section name
+var factsScript = strings.Join([]string{
+	"echo '" + factsMarker + "os'; cat /etc/os-release 2>/dev/null",
+	"echo '" + factsMarker + "kernel'; uname -r",
+	"echo '" + factsMarker + "cpu'; nproc; grep -m1 '^model name' /proc/cpuinfo | cut -d: -f2",
+	"echo '" + factsMarker + "memory'; grep -E '^(MemTotal|MemAvailable):' /proc/meminfo",
+	"echo '" + factsMarker + "disks'; df -P -k 2>/dev/null | grep '^/dev/'",
+	"echo '" + factsMarker + "interfaces'; ip -o addr show 2>/dev/null",
+	"echo '" + factsMarker + "packages'; dpkg-query -W -f '${Package}\\t${Version}\\n' 2>/dev/null || rpm -qa --qf '%{NAME}\\t%{VERSION}-%{RELEASE}\\n' 2>/dev/null || apk info -v 2>/dev/null",
+	"echo '" + factsMarker + "services'; if [ -d /run/systemd/system ]; then systemctl list-units --type=service --state=running --no-legend --plain | cut -d' ' -f1 | sed 's/\\.service$//'; else service --status-all 2>/dev/null | grep -F '[ + ]' | awk '{print $4}'; fi",
+}, "; ")
+
+// Facts gathers the facts of the target
+func (r *Remote) Facts(ctx context.Context) (*types.Facts, error) {
+	res, err := r.run(factsScript, bytes.NewBufferString(""))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not gather facts")
+	}
+
+	return parseFacts(res.Stdout.String()), nil
+}
+
+// parseFacts parses the output of factsScript, missing sections are left empty
+func parseFacts(out string) *types.Facts {
+	facts := types.Facts{
+		Packages: map[string]string{},
+	}
+
+	sections := map[string][]string{}
+	section := ""
+	for _, line := range strings.Split(out, "\n") {
+		if strings.HasPrefix(line, factsMarker) {
+			section = strings.TrimPrefix(line, factsMarker)
+			continue
+		}
+
+		if section != "" && strings.TrimSpace(line) != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+
+	release := parseOSRelease(strings.Join(sections["os"], "\n"))
+	facts.OS = types.OS{
+		ID:       release["ID"],
+		Name:     release["NAME"],
+		Version:  release["VERSION_ID"],
+		Codename: release["VERSION_CODENAME"],
+		Like:     strings.Fields(release["ID_LIKE"]),
+	}
+
+	if kernel := sections["kernel"]; len(kernel) > 0 {
+		facts.Kernel = strings.TrimSpace(kernel[0])
+	}
+
+	if cpu := sections["cpu"]; len(cpu) > 0 {
+		facts.CPU.Count, _ = strconv.Atoi(strings.TrimSpace(cpu[0]))
+		if len(cpu) > 1 {
+			facts.CPU.Model = strings.TrimSpace(cpu[1])
+		}
+	}
+
+	for _, line := range sections["memory"] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		kb, _ := strconv.Atoi(fields[1])
+		switch fields[0] {
+		case "MemTotal:":
+			facts.Memory.TotalMB = kb / 1024
+		case "MemAvailable:":
+			facts.Memory.AvailableMB = kb / 1024
+		}
+	}
+
+	// df -P -k: filesystem, size, used, available, capacity and mount point
+	for _, line := range sections["disks"] {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+
+		size, _ := strconv.Atoi(fields[1])
+		available, _ := strconv.Atoi(fields[3])
+		facts.Disks = append(facts.Disks, types.Disk{
+			Device:      fields[0],
+			Mount:       fields[5],
+			SizeMB:      size / 1024,
+			AvailableMB: available / 1024,
+		})
+	}
+
+	// ip -o addr show: index, name, family, address/prefix, ...
+	interfaces := map[string]int{}
+	for _, line := range sections["interfaces"] {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		name := fields[1]
+		i, ok := interfaces[name]
+		if !ok {
+			i = len(facts.Interfaces)
+			interfaces[name] = i
+			facts.Interfaces = append(facts.Interfaces, types.Interface{Name: name})
+		}
+
+		facts.Interfaces[i].Addresses = append(facts.Interfaces[i].Addresses, fields[3])
+	}
+
+	for _, line := range sections["packages"] {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) == 2 {
+			facts.Packages[fields[0]] = fields[1]
+			continue
+		}
+
+		// apk info -v lists <name>-<version>
+		name, version := splitAPKVersion(fields[0])
+		facts.Packages[name] = version
+	}
+
+	for _, line := range sections["services"] {
+		facts.Services = append(facts.Services, strings.TrimSpace(line))
+	}
+
+	return &facts
+}
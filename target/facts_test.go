@@ -0,0 +1,80 @@
+package target
+
+import (
+	"testing"
+)
+
+const factsOutput = `### goconf:os
+NAME="Ubuntu"
+VERSION_ID="22.04"
+ID=ubuntu
+ID_LIKE=debian
+VERSION_CODENAME=jammy
+### goconf:kernel
+5.15.0-58-generic
+### goconf:cpu
+2
+ Intel(R) Xeon(R) CPU E5-2676 v3 @ 2.40GHz
+### goconf:memory
+MemTotal:        4015424 kB
+MemAvailable:    3221568 kB
+### goconf:disks
+/dev/root        30297152 2541340  27739428       9% /
+/dev/xvda15        106858    5329    101529       5% /boot/efi
+### goconf:interfaces
+1: lo    inet 127.0.0.1/8 scope host lo\       valid_lft forever preferred_lft forever
+2: eth0    inet 172.31.3.4/20 brd 172.31.15.255 scope global dynamic eth0\       valid_lft 3106sec preferred_lft 3106sec
+2: eth0    inet6 fe80::8ff:feff:fe3c:1/64 scope link \       valid_lft forever preferred_lft forever
+### goconf:packages
+apache2	2.4.52-1ubuntu4.3
+php8.1	8.1.2-1ubuntu2.10
+### goconf:services
+apache2
+ssh
+`
+
+func TestParseFacts(t *testing.T) {
+	facts := parseFacts(factsOutput)
+
+	if facts.OS.ID != "ubuntu" || facts.OS.Version != "22.04" || facts.OS.Codename != "jammy" {
+		t.Errorf("expected %v and got %v", "ubuntu 22.04 jammy", facts.OS)
+	}
+
+	if facts.Kernel != "5.15.0-58-generic" {
+		t.Errorf("expected %v and got %v", "5.15.0-58-generic", facts.Kernel)
+	}
+
+	if facts.CPU.Count != 2 {
+		t.Errorf("expected %v and got %v", 2, facts.CPU.Count)
+	}
+
+	if facts.Memory.TotalMB != 3921 {
+		t.Errorf("expected %v and got %v", 3921, facts.Memory.TotalMB)
+	}
+
+	if len(facts.Disks) != 2 || facts.Disks[0].Mount != "/" {
+		t.Errorf("expected %v and got %v", "2 disks", facts.Disks)
+	}
+
+	if len(facts.Interfaces) != 2 || len(facts.Interfaces[1].Addresses) != 2 {
+		t.Errorf("expected %v and got %v", "lo and eth0 with 2 addresses", facts.Interfaces)
+	}
+
+	if facts.Packages["php8.1"] != "8.1.2-1ubuntu2.10" {
+		t.Errorf("expected %v and got %v", "8.1.2-1ubuntu2.10", facts.Packages["php8.1"])
+	}
+
+	if len(facts.Services) != 2 {
+		t.Errorf("expected %v and got %v", 2, len(facts.Services))
+	}
+
+	facts = parseFacts("### goconf:packages\nphp81-8.1.16-r0\n")
+	if facts.Packages["php81"] != "8.1.16-r0" {
+		t.Errorf("expected %v and got %v", "8.1.16-r0", facts.Packages["php81"])
+	}
+
+	facts = parseFacts("test")
+	if facts.OS.ID != "" || len(facts.Packages) != 0 {
+		t.Errorf("expected empty facts and got %v", facts)
+	}
+}
@@ -0,0 +1,157 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/slack/target/types"
+)
+
+// remoteChecksum returns the sha256 checksum of the remote file
+func (r *Remote) remoteChecksum(remotePath string) (string, error) {
+	res, err := r.run(fmt.Sprintf("sha256sum %s", shellQuote(remotePath)), bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return "", errors.Errorf("could not get checksum of %s: %v %s", remotePath, err, res.Stderr.String())
+	}
+
+	return parseChecksum(res.Stdout.String())
+}
+
+// parseChecksum reads the checksum from the output of sha256sum
+func parseChecksum(out string) (string, error) {
+	fields := strings.Fields(out)
+	if len(fields) == 0 || len(fields[0]) != sha256.Size*2 {
+		return "", fmt.Errorf("invalid checksum %q", strings.TrimSpace(out))
+	}
+
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", fmt.Errorf("invalid checksum %q", fields[0])
+	}
+
+	return fields[0], nil
+}
+
+// localChecksum returns the sha256 checksum of the local file
+func localChecksum(localPath string) (string, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to read file %s", localPath)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fetchAttempts is the number of downloads of a file which changes while it's
+// downloaded, e.g. a log file
+const fetchAttempts = 2
+
+// download copies the remote file to partPath and returns its checksum
+func download(client *sftp.Client, remotePath, partPath string) (string, error) {
+	srcFile, err := client.Open(remotePath)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to open file %s", remotePath)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.Create(partPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create file %s", partPath)
+	}
+	defer dstFile.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(dstFile, h), srcFile)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to copy file %s", remotePath)
+	}
+
+	err = dstFile.Close()
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to write file %s", partPath)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Fetch downloads the remote file to localPath unless it's already there. The
+// download is verified against the checksum of the remote file after the
+// download and only moved to localPath when it matches. A file which changed
+// during the download is downloaded once more.
+func (r *Remote) Fetch(ctx context.Context, remotePath, localPath string) (types.StatusCode, error) {
+	checksum, err := r.remoteChecksum(remotePath)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	current, err := localChecksum(localPath)
+	if err != nil && !os.IsNotExist(err) {
+		return types.StatusFailed, err
+	}
+
+	if current == checksum {
+		return types.StatusSatisfied, nil
+	}
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	err = os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "unable to create directory for %s", localPath)
+	}
+
+	// the download is written next to localPath, so a failed or corrupted
+	// download does not replace the last fetched file
+	partPath := localPath + ".part"
+	defer os.Remove(partPath)
+
+	for attempt := 1; ; attempt++ {
+		fmt.Printf("trying to fetch %s from %s ...\n", remotePath, r.addr)
+
+		sum, err := download(client, remotePath, partPath)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		checksum, err = r.remoteChecksum(remotePath)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		if sum == checksum {
+			break
+		}
+
+		if attempt == fetchAttempts {
+			return types.StatusFailed, fmt.Errorf("checksum mismatch for %s, expected %s and got %s, the file changes during the download", remotePath, checksum, sum)
+		}
+
+		fmt.Printf("%s changed during the download from %s\n", remotePath, r.addr)
+	}
+
+	err = os.Rename(partPath, localPath)
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "unable to move file to %s", localPath)
+	}
+
+	fmt.Printf("%s successfully fetched from %s\n", remotePath, r.addr)
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,25 @@
+package target
+
+import (
+	"testing"
+)
+
+func TestParseChecksum(t *testing.T) {
+	sum := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	checksum, err := parseChecksum(sum + "  /etc/php/8.1/apache2/php.ini\n")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if checksum != sum {
+		t.Errorf("expected %v and got %v", sum, checksum)
+	}
+
+	for _, out := range []string{"", "test", "sha256sum: /etc/missing: No such file or directory"} {
+		_, err := parseChecksum(out)
+		if err == nil {
+			t.Errorf("%s: expected error and got nil", out)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/slack/target/types"
+)
+
+// defaultFileMode is the mode of transfer_files without a mode
+const defaultFileMode = 0644
+
+// readFile reads a remote file via sftp
+func (r *Remote) readFile(path string) ([]byte, error) {
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get sftp client")
+	}
+
+	f, err := sftp.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(f)
+}
+
+// writeFile writes content to a remote file via sftp if it differs from the
+// current content. It returns true when the file has been changed.
+func (r *Remote) writeFile(path string, content []byte, mode os.FileMode) (bool, error) {
+	current, err := r.readFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, errors.Wrapf(err, "unable to read file %s", path)
+	}
+
+	if err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+
+	err = r.replaceFile(path, content, mode)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// replaceFile writes content to a temporary file next to p and renames it to p,
+// so the file is never seen partially written. The owner of an existing file is
+// kept and a link is kept pointing to the replaced file.
+func (r *Remote) replaceFile(p string, content []byte, mode os.FileMode) error {
+	client, err := r.sftpClient()
+	if err != nil {
+		return errors.Wrap(err, "could not get sftp client")
+	}
+
+	if st, err := client.Lstat(p); err == nil && st.Mode()&os.ModeSymlink != 0 {
+		target, err := client.ReadLink(p)
+		if err != nil {
+			return errors.Wrapf(err, "unable to read link %s", p)
+		}
+
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(p), target)
+		}
+		p = target
+	}
+
+	current, err := client.Stat(p)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "unable to stat %s", p)
+	}
+
+	tmpPath := path.Join(path.Dir(p), "."+path.Base(p)+".goconf")
+	f, err := client.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create file %s", tmpPath)
+	}
+
+	renamed := false
+	defer func() {
+		if !renamed {
+			client.Remove(tmpPath)
+		}
+	}()
+
+	// the mode is set before the content is written, so private keys are
+	// never readable by others
+	err = client.Chmod(tmpPath, mode)
+	if err != nil {
+		f.Close()
+		return errors.Wrap(err, "chmod error")
+	}
+
+	_, err = f.Write(content)
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "unable to write file %s", tmpPath)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "unable to write file %s", tmpPath)
+	}
+
+	if current != nil {
+		if stat, ok := current.Sys().(*sftp.FileStat); ok {
+			err = client.Chown(tmpPath, int(stat.UID), int(stat.GID))
+			if err != nil {
+				return errors.Wrap(err, "chown error")
+			}
+		}
+	}
+
+	err = client.PosixRename(tmpPath, p)
+	if err != nil {
+		return errors.Wrapf(err, "unable to replace file %s", p)
+	}
+
+	renamed = true
+	return nil
+}
+
+// EnsureFile ensures the state of a transfer_files entry on the remote. It's
+// satisfied when the file, directory or link is already as desired.
+func (r *Remote) EnsureFile(ctx context.Context, f types.File) (types.StatusCode, error) {
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	current, err := sftp.Lstat(f.RemotePath)
+	if err != nil && !os.IsNotExist(err) {
+		return types.StatusFailed, errors.Wrapf(err, "unable to stat %s", f.RemotePath)
+	}
+
+	var changed bool
+	switch f.State {
+	case "", types.FileStateFile:
+		changed, err = r.ensureContent(f, current)
+	case types.FileStateDirectory:
+		changed, err = r.ensureDirectory(f, current)
+	case types.FileStateLink:
+		changed, err = r.ensureLink(f, current)
+	case types.FileStateAbsent:
+		changed, err = r.ensureAbsent(f, current)
+	default:
+		err = fmt.Errorf("unknown state %s of %s", f.State, f.RemotePath)
+	}
+
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !changed {
+		return types.StatusSatisfied, nil
+	}
+
+	state := f.State
+	if state == "" {
+		state = types.FileStateFile
+	}
+
+	fmt.Printf("%s is ensured as %s on %s\n", f.RemotePath, state, r.addr)
+	return types.StatusEnforced, nil
+}
+
+// ensureContent writes the inline content or the local file to the remote path
+// and applies the mode and the owner
+func (r *Remote) ensureContent(f types.File, current os.FileInfo) (bool, error) {
+	if current != nil && current.IsDir() {
+		return false, fmt.Errorf("%s is a directory", f.RemotePath)
+	}
+
+	// a missing localpath, e.g. a typo in its key, must not empty the remote file
+	if (f.LocalPath == "") == (f.Content == "") {
+		return false, fmt.Errorf("%s needs either a localpath or a content", f.RemotePath)
+	}
+
+	content := []byte(f.Content)
+	if f.LocalPath != "" {
+		var err error
+		content, err = os.ReadFile(f.LocalPath)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to read file %s", f.LocalPath)
+		}
+	}
+
+	mode := os.FileMode(defaultFileMode)
+	if f.Mode != 0 {
+		mode = os.FileMode(f.Mode).Perm()
+	}
+
+	uid, gid, err := r.fileOwner(f)
+	if err != nil {
+		return false, err
+	}
+
+	written, err := r.writeFile(f.RemotePath, content, mode)
+	if err != nil {
+		return false, err
+	}
+
+	if written {
+		if isUnitFile(f.RemotePath) {
+			r.unitsChanged = true
+		}
+
+		// the written file gets the mode and owner unconditionally
+		current = nil
+	}
+
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return false, errors.Wrap(err, "could not get sftp client")
+	}
+
+	changed, err := syncAttrs(sftp, f.RemotePath, current, mode, uid, gid)
+	return written || changed, err
+}
+
+// ensureDirectory creates the remote directory and applies the mode and the owner
+func (r *Remote) ensureDirectory(f types.File, current os.FileInfo) (bool, error) {
+	if current != nil && !current.IsDir() {
+		return false, fmt.Errorf("%s exists and is not a directory", f.RemotePath)
+	}
+
+	mode := os.FileMode(defaultDirMode)
+	if f.Mode != 0 {
+		mode = os.FileMode(f.Mode).Perm()
+	}
+
+	uid, gid, err := r.fileOwner(f)
+	if err != nil {
+		return false, err
+	}
+
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return false, errors.Wrap(err, "could not get sftp client")
+	}
+
+	return syncDir(sftp, f.RemotePath, current, mode, uid, gid)
+}
+
+// ensureLink points the remote path to the target, an existing file is replaced
+func (r *Remote) ensureLink(f types.File, current os.FileInfo) (bool, error) {
+	if f.Target == "" {
+		return false, fmt.Errorf("link %s has no target", f.RemotePath)
+	}
+
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return false, errors.Wrap(err, "could not get sftp client")
+	}
+
+	if current != nil {
+		if current.Mode()&os.ModeSymlink != 0 {
+			target, err := sftp.ReadLink(f.RemotePath)
+			if err != nil {
+				return false, errors.Wrapf(err, "unable to read link %s", f.RemotePath)
+			}
+
+			if target == f.Target {
+				return false, nil
+			}
+		}
+
+		if current.IsDir() {
+			return false, fmt.Errorf("%s is a directory", f.RemotePath)
+		}
+
+		err = sftp.Remove(f.RemotePath)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to replace %s", f.RemotePath)
+		}
+	}
+
+	err = sftp.Symlink(f.Target, f.RemotePath)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to link %s to %s", f.RemotePath, f.Target)
+	}
+
+	return true, nil
+}
+
+// ensureAbsent removes the remote file, link or directory with its content
+func (r *Remote) ensureAbsent(f types.File, current os.FileInfo) (bool, error) {
+	if current == nil {
+		return false, nil
+	}
+
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return false, errors.Wrap(err, "could not get sftp client")
+	}
+
+	if !current.IsDir() {
+		err = sftp.Remove(f.RemotePath)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to remove %s", f.RemotePath)
+		}
+		return true, nil
+	}
+
+	tree, err := remoteTree(sftp, f.RemotePath)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = deleteExtraneous(sftp, tree, map[string]bool{})
+	return true, err
+}
@@ -0,0 +1,529 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// the firewall backends
+const (
+	FirewallUFW      = "ufw"
+	FirewallNftables = "nftables"
+)
+
+// the default policies of ufw, the nftables ruleset of goconf and the main
+// config including it
+var (
+	ufwDefaults = "/etc/default/ufw"
+	nftFile     = "/etc/nftables.d/goconf.nft"
+	nftConf     = "/etc/nftables.conf"
+)
+
+// ufwPolicies maps the policies of /etc/default/ufw to the policies of ufw
+var ufwPolicies = map[string]string{
+	"ACCEPT": "allow",
+	"DROP":   "deny",
+	"REJECT": "reject",
+}
+
+// validPolicy checks the default policy p, empty leaves the policy untouched
+func validPolicy(p string) error {
+	switch p {
+	case "", "allow", "deny", "reject":
+		return nil
+	}
+
+	return fmt.Errorf("unknown firewall policy %s", p)
+}
+
+// ruleAction returns the action of rule, allow by default
+func ruleAction(rule types.FirewallRule) (string, error) {
+	switch rule.Action {
+	case "":
+		return "allow", nil
+	case "allow", "deny", "reject", "limit":
+		return rule.Action, nil
+	}
+
+	return "", fmt.Errorf("unknown firewall action %s", rule.Action)
+}
+
+// anySource checks if the rule matches every source address
+func anySource(rule types.FirewallRule) bool {
+	return rule.From == "" || rule.From == "any"
+}
+
+// coversPort checks if the rule matches tcp traffic to port
+func coversPort(rule types.FirewallRule, port int) bool {
+	if rule.Proto != "" && rule.Proto != "tcp" {
+		return false
+	}
+
+	if rule.App != "" {
+		return rule.App == "OpenSSH" && port == 22
+	}
+
+	bounds := strings.SplitN(rule.Port, ":", 2)
+	low, err := strconv.Atoi(bounds[0])
+	if err != nil {
+		return false
+	}
+
+	high := low
+	if len(bounds) == 2 {
+		high, err = strconv.Atoi(bounds[1])
+		if err != nil {
+			return false
+		}
+	}
+
+	return low <= port && port <= high
+}
+
+// checkSSH refuses firewall settings which block the SSH port goconf is
+// connected on. incoming is the default incoming policy once fw is applied,
+// allowed is set when the port is already allowed by a rule which is kept and
+// replies is set when the outgoing replies of established connections are
+// accepted whatever the outgoing policy.
+func checkSSH(fw types.Firewall, incoming string, port int, allowed bool, replies bool) error {
+	if (fw.Outgoing == "deny" || fw.Outgoing == "reject") && !replies {
+		return errors.Errorf("refusing the %s outgoing policy without accepting the established traffic of the SSH port %d", fw.Outgoing, port)
+	}
+
+	for _, rule := range fw.Rules {
+		if !coversPort(rule, port) {
+			continue
+		}
+
+		action, err := ruleAction(rule)
+		if err != nil {
+			return err
+		}
+
+		if rule.State == types.StateAbsent {
+			continue
+		}
+
+		if action == "deny" || action == "reject" {
+			return errors.Errorf("refusing to %s the SSH port %d goconf is connected on", action, port)
+		}
+
+		if anySource(rule) {
+			allowed = true
+		}
+	}
+
+	if (incoming == "deny" || incoming == "reject") && !allowed {
+		return errors.Errorf("refusing the %s incoming policy without a rule allowing the SSH port %d from anywhere", incoming, port)
+	}
+
+	return nil
+}
+
+// ufwRule returns the args of the ufw cmd adding rule, in the form `ufw show
+// added` prints them
+func ufwRule(rule types.FirewallRule) ([]string, error) {
+	action, err := ruleAction(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	if (rule.Port == "") == (rule.App == "") {
+		return nil, errors.New("firewall rule needs either a port or an app")
+	}
+
+	switch rule.Proto {
+	case "", "tcp", "udp":
+	default:
+		return nil, fmt.Errorf("unknown protocol %s", rule.Proto)
+	}
+
+	if strings.Contains(rule.Port, ":") && rule.Proto == "" {
+		return nil, fmt.Errorf("port range %s needs a protocol", rule.Port)
+	}
+
+	if anySource(rule) {
+		if rule.App != "" {
+			return []string{action, rule.App}, nil
+		}
+
+		port := rule.Port
+		if rule.Proto != "" {
+			port += "/" + rule.Proto
+		}
+		return []string{action, port}, nil
+	}
+
+	args := []string{action, "from", rule.From, "to", "any"}
+	if rule.App != "" {
+		return append(args, "app", rule.App), nil
+	}
+
+	args = append(args, "port", rule.Port)
+	if rule.Proto != "" {
+		args = append(args, "proto", rule.Proto)
+	}
+
+	return args, nil
+}
+
+// ufwKey returns the comparable form of the args of an ufw rule
+func ufwKey(args string) string {
+	return strings.Join(strings.Fields(strings.NewReplacer("'", "", `"`, "").Replace(args)), " ")
+}
+
+// parseUFWAdded returns the rules printed by `ufw show added`
+func parseUFWAdded(out string) map[string]bool {
+	rules := map[string]bool{}
+	for _, line := range splitLines(out) {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "ufw ") {
+			continue
+		}
+
+		// comments are not part of the rule
+		if i := strings.Index(line, " comment "); i >= 0 {
+			line = line[:i]
+		}
+		rules[ufwKey(strings.TrimPrefix(line, "ufw "))] = true
+	}
+
+	return rules
+}
+
+// parseUFWDefaults returns the incoming and outgoing policies of /etc/default/ufw
+func parseUFWDefaults(content string) (string, string) {
+	policies := map[string]string{}
+	for _, line := range splitLines(content) {
+		kv := strings.SplitN(strings.TrimSpace(line), "=", 2)
+		if len(kv) == 2 {
+			policies[kv[0]] = ufwPolicies[strings.Trim(kv[1], `"`)]
+		}
+	}
+
+	return policies["DEFAULT_INPUT_POLICY"], policies["DEFAULT_OUTPUT_POLICY"]
+}
+
+// ufwAllowsSSH checks if one of the added rules allows port from anywhere and
+// is not deleted by fw
+func ufwAllowsSSH(fw types.Firewall, added map[string]bool, port int) bool {
+	deleted := map[string]bool{}
+	for _, rule := range fw.Rules {
+		if rule.State != types.StateAbsent {
+			continue
+		}
+
+		if args, err := ufwRule(rule); err == nil {
+			deleted[ufwKey(strings.Join(args, " "))] = true
+		}
+	}
+
+	candidates := []string{}
+	for _, action := range []string{"allow", "limit"} {
+		candidates = append(candidates, fmt.Sprintf("%s %d", action, port), fmt.Sprintf("%s %d/tcp", action, port))
+		if port == 22 {
+			candidates = append(candidates, action+" OpenSSH")
+		}
+	}
+
+	for _, key := range candidates {
+		if added[key] && !deleted[key] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sshPort returns the port of the SSH connection
+func (r *Remote) sshPort() (int, error) {
+	_, port, err := net.SplitHostPort(r.addr)
+	if err != nil {
+		return 22, nil
+	}
+
+	return strconv.Atoi(port)
+}
+
+// EnsureFirewall applies the default policies and the rules of fw, it refuses
+// settings which would block the SSH port goconf is connected on
+func (r *Remote) EnsureFirewall(ctx context.Context, fw types.Firewall) (types.StatusCode, error) {
+	for _, p := range []string{fw.Incoming, fw.Outgoing} {
+		if err := validPolicy(p); err != nil {
+			return types.StatusFailed, err
+		}
+	}
+
+	switch fw.Backend {
+	case "", FirewallUFW:
+		return r.ensureUFW(fw)
+	case FirewallNftables:
+		return r.ensureNftables(fw)
+	}
+
+	return types.StatusFailed, fmt.Errorf("unknown firewall backend %s", fw.Backend)
+}
+
+// ensureUFW adds and deletes the rules with ufw, sets the policies and
+// enables ufw. The rules are added before the policies, so the SSH port is
+// allowed before the default policy blocks it.
+func (r *Remote) ensureUFW(fw types.Firewall) (types.StatusCode, error) {
+	port, err := r.sshPort()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "invalid SSH port")
+	}
+
+	res, err := r.run("command -v ufw", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.New("ufw is not installed")
+	}
+
+	content, err := r.readFile(ufwDefaults)
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "unable to read the ufw policies")
+	}
+	incoming, outgoing := parseUFWDefaults(string(content))
+
+	res, err = r.run("sudo ufw show added", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not list ufw rules: %v %s", err, res.Stderr.String())
+	}
+	added := parseUFWAdded(res.Stdout.String())
+
+	desired := incoming
+	if fw.Incoming != "" {
+		desired = fw.Incoming
+	}
+
+	// the outgoing replies are accepted by the before rules of ufw
+	err = checkSSH(fw, desired, port, ufwAllowsSSH(fw, added, port), true)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	cmds := []string{}
+	for _, rule := range fw.Rules {
+		args, err := ufwRule(rule)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = shellQuote(arg)
+		}
+
+		exists := added[ufwKey(strings.Join(args, " "))]
+		switch rule.State {
+		case "", types.StatePresent:
+			if !exists {
+				cmds = append(cmds, "sudo ufw "+strings.Join(quoted, " "))
+			}
+		case types.StateAbsent:
+			if exists {
+				cmds = append(cmds, "sudo ufw delete "+strings.Join(quoted, " "))
+			}
+		default:
+			return types.StatusFailed, fmt.Errorf("unknown state %s of firewall rule", rule.State)
+		}
+	}
+
+	if fw.Incoming != "" && fw.Incoming != incoming {
+		cmds = append(cmds, "sudo ufw default "+fw.Incoming+" incoming")
+	}
+
+	if fw.Outgoing != "" && fw.Outgoing != outgoing {
+		cmds = append(cmds, "sudo ufw default "+fw.Outgoing+" outgoing")
+	}
+
+	res, err = r.run("sudo ufw status", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not read ufw status: %v %s", err, res.Stderr.String())
+	}
+
+	if !strings.Contains(res.Stdout.String(), "Status: active") {
+		cmds = append(cmds, "sudo ufw --force enable")
+	}
+
+	if len(cmds) == 0 {
+		return types.StatusSatisfied, nil
+	}
+
+	for _, cmd := range cmds {
+		err = r.change(cmd)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+	}
+
+	return types.StatusEnforced, nil
+}
+
+// nftPolicies maps the policies to the verdicts of nftables
+var nftPolicies = map[string]string{
+	"":       "accept",
+	"allow":  "accept",
+	"deny":   "drop",
+	"reject": "reject",
+}
+
+// nftRule returns the nftables rule of rule in the input chain
+func nftRule(rule types.FirewallRule) (string, error) {
+	action, err := ruleAction(rule)
+	if err != nil {
+		return "", err
+	}
+
+	if rule.App != "" {
+		return "", fmt.Errorf("app %s is only supported by ufw", rule.App)
+	}
+
+	if action == "limit" {
+		return "", errors.New("limit is only supported by ufw")
+	}
+
+	if rule.Port == "" {
+		return "", errors.New("firewall rule needs a port")
+	}
+
+	port := strings.Replace(rule.Port, ":", "-", 1)
+
+	match := "meta l4proto { tcp, udp } th dport " + port
+	switch rule.Proto {
+	case "":
+	case "tcp", "udp":
+		match = rule.Proto + " dport " + port
+	default:
+		return "", fmt.Errorf("unknown protocol %s", rule.Proto)
+	}
+
+	if !anySource(rule) {
+		family := "ip"
+		if strings.Contains(rule.From, ":") {
+			family = "ip6"
+		}
+		match = fmt.Sprintf("%s saddr %s %s", family, rule.From, match)
+	}
+
+	return match + " " + nftPolicies[action], nil
+}
+
+// nftRuleset returns the goconf table of fw. The table is deleted before it's
+// created, so loading the file again replaces the rules.
+func nftRuleset(fw types.Firewall) (string, error) {
+	rules := []string{}
+	for _, rule := range fw.Rules {
+		switch rule.State {
+		case "", types.StatePresent:
+		case types.StateAbsent:
+			continue
+		default:
+			return "", fmt.Errorf("unknown state %s of firewall rule", rule.State)
+		}
+
+		line, err := nftRule(rule)
+		if err != nil {
+			return "", err
+		}
+		rules = append(rules, "\t\t"+line)
+	}
+
+	var b strings.Builder
+	b.WriteString("# managed by goconf\ntable inet goconf\ndelete table inet goconf\n\n")
+	b.WriteString("table inet goconf {\n\tchain input {\n")
+	fmt.Fprintf(&b, "\t\ttype filter hook input priority 0; policy %s;\n", nftPolicies[fw.Incoming])
+	b.WriteString("\t\tct state established,related accept\n\t\tiif \"lo\" accept\n")
+	for _, rule := range rules {
+		b.WriteString(rule + "\n")
+	}
+	b.WriteString("\t}\n\n\tchain output {\n")
+	fmt.Fprintf(&b, "\t\ttype filter hook output priority 0; policy %s;\n", nftPolicies[fw.Outgoing])
+	b.WriteString("\t\tct state established,related accept\n\t\toif \"lo\" accept\n")
+	b.WriteString("\t}\n}\n")
+
+	return b.String(), nil
+}
+
+// nftAcceptsReplies checks if the output chain of ruleset accepts the traffic
+// of established connections
+func nftAcceptsReplies(ruleset string) bool {
+	i := strings.Index(ruleset, "chain output {")
+	if i < 0 {
+		return false
+	}
+
+	chain := ruleset[i:]
+	if end := strings.Index(chain, "}"); end >= 0 {
+		chain = chain[:end]
+	}
+
+	return strings.Contains(chain, "ct state established,related accept")
+}
+
+// ensureNftables writes the goconf table to nftFile, includes it in nftConf
+// and loads it when it changed. The ruleset is checked by nft before it's written.
+func (r *Remote) ensureNftables(fw types.Firewall) (types.StatusCode, error) {
+	port, err := r.sshPort()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "invalid SSH port")
+	}
+
+	ruleset, err := nftRuleset(fw)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	// empty policies accept everything with nftables
+	err = checkSSH(fw, fw.Incoming, port, false, nftAcceptsReplies(ruleset))
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	// the ruleset is checked before it's written, so an invalid ruleset is
+	// not left in place for the next run
+	res, err := r.run("sudo nft -c -f /dev/stdin", bytes.NewBufferString(ruleset))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("nftables ruleset is invalid: %v %s", err, strings.TrimSpace(res.Stderr.String()))
+	}
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	err = client.MkdirAll(path.Dir(nftFile))
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "unable to create directory of %s", nftFile)
+	}
+
+	written, err := r.writeFile(nftFile, []byte(ruleset), 0644)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	included, err := r.editFile(nftConf, true, func(content string) (string, error) {
+		return editLine(content, types.Line{Line: fmt.Sprintf("include %q", nftFile)})
+	})
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !written && !included {
+		return types.StatusSatisfied, nil
+	}
+
+	err = r.change("sudo nft -f " + shellQuote(nftFile))
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,141 @@
+package target
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestCheckSSH(t *testing.T) {
+	ssh := types.FirewallRule{Port: "22", Proto: "tcp"}
+	web := types.FirewallRule{App: "Apache Full"}
+
+	cases := []struct {
+		fw       types.Firewall
+		incoming string
+		allowed  bool
+		ok       bool
+	}{
+		{types.Firewall{Rules: []types.FirewallRule{ssh, web}}, "deny", false, true},
+		{types.Firewall{Rules: []types.FirewallRule{web}}, "deny", false, false},
+		{types.Firewall{Rules: []types.FirewallRule{web}}, "deny", true, true},
+		{types.Firewall{Rules: []types.FirewallRule{web}}, "allow", false, true},
+		{types.Firewall{Rules: []types.FirewallRule{{App: "OpenSSH", Action: "limit"}}}, "reject", false, true},
+		{types.Firewall{Rules: []types.FirewallRule{{Port: "20:30", Proto: "tcp", Action: "deny"}}}, "allow", true, false},
+		{types.Firewall{Rules: []types.FirewallRule{{Port: "22", From: "10.0.0.0/8"}}}, "deny", false, false},
+		{types.Firewall{Rules: []types.FirewallRule{{Port: "22", Proto: "udp", Action: "deny"}}}, "", false, true},
+	}
+
+	for i, c := range cases {
+		err := checkSSH(c.fw, c.incoming, 22, c.allowed, true)
+		if (err == nil) != c.ok {
+			t.Errorf("case %d: expected %v and got %v", i, c.ok, err)
+		}
+	}
+
+	err := checkSSH(types.Firewall{Outgoing: "deny", Rules: []types.FirewallRule{ssh}}, "deny", 22, false, false)
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
+
+func TestUFWRule(t *testing.T) {
+	cases := map[string]types.FirewallRule{
+		"allow 80/tcp":  {Port: "80", Proto: "tcp"},
+		"limit OpenSSH": {App: "OpenSSH", Action: "limit"},
+		"allow from 10.0.0.0/8 to any port 3306 proto tcp": {Port: "3306", Proto: "tcp", From: "10.0.0.0/8"},
+		"deny from 192.168.1.5 to any app Apache Full":     {App: "Apache Full", From: "192.168.1.5", Action: "deny"},
+	}
+
+	for expected, rule := range cases {
+		args, err := ufwRule(rule)
+		if err != nil || strings.Join(args, " ") != expected {
+			t.Errorf("expected %v and got %v %v", expected, args, err)
+		}
+	}
+
+	for _, rule := range []types.FirewallRule{{}, {Port: "80", App: "Apache"}, {Port: "8000:8100"}, {Port: "80", Action: "drop"}, {Port: "80", Proto: "icmp"}} {
+		_, err := ufwRule(rule)
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", rule)
+		}
+	}
+
+	added := parseUFWAdded("Added user rules (see 'ufw status' for running firewall):\nufw allow 2222/tcp\nufw allow 'Apache Full' comment 'web'\n")
+	if !added["allow 2222/tcp"] || !added["allow Apache Full"] || len(added) != 2 {
+		t.Errorf("expected %v and got %v", "2 rules", added)
+	}
+
+	if !ufwAllowsSSH(types.Firewall{}, added, 2222) {
+		t.Errorf("expected the SSH port to be allowed")
+	}
+
+	if ufwAllowsSSH(types.Firewall{Rules: []types.FirewallRule{{Port: "2222", Proto: "tcp", State: types.StateAbsent}}}, added, 2222) {
+		t.Errorf("expected the SSH port to be deleted")
+	}
+
+	incoming, outgoing := parseUFWDefaults("IPV6=yes\nDEFAULT_INPUT_POLICY=\"DROP\"\nDEFAULT_OUTPUT_POLICY=\"ACCEPT\"\n")
+	if incoming != "deny" || outgoing != "allow" {
+		t.Errorf("expected %v and got %v %v", "deny allow", incoming, outgoing)
+	}
+}
+
+func TestNftRuleset(t *testing.T) {
+	fw := types.Firewall{
+		Incoming: "deny",
+		Rules: []types.FirewallRule{
+			{Port: "22", Proto: "tcp"},
+			{Port: "8000:8100"},
+			{Port: "3306", Proto: "tcp", From: "10.0.0.0/8"},
+			{Port: "11211", State: types.StateAbsent},
+		},
+	}
+
+	ruleset, err := nftRuleset(fw)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	for _, expected := range []string{
+		"policy drop;",
+		"tcp dport 22 accept",
+		"meta l4proto { tcp, udp } th dport 8000-8100 accept",
+		"ip saddr 10.0.0.0/8 tcp dport 3306 accept",
+		"type filter hook output priority 0; policy accept;",
+	} {
+		if !strings.Contains(ruleset, expected) {
+			t.Errorf("expected %q in %q", expected, ruleset)
+		}
+	}
+
+	if strings.Contains(ruleset, "11211") {
+		t.Errorf("expected absent rules to be left out")
+	}
+
+	fw.Outgoing = "deny"
+	ruleset, err = nftRuleset(fw)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	output := ruleset[strings.Index(ruleset, "chain output {"):]
+	for _, expected := range []string{
+		"type filter hook output priority 0; policy drop;",
+		"ct state established,related accept",
+		`oif "lo" accept`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in %q", expected, output)
+		}
+	}
+
+	if !nftAcceptsReplies(ruleset) {
+		t.Errorf("expected the output chain to accept established traffic")
+	}
+
+	_, err = nftRuleset(types.Firewall{Rules: []types.FirewallRule{{App: "OpenSSH"}}})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
@@ -0,0 +1,293 @@
+package target
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// idCache holds the passwd and group databases of the target, they are read
+// once and dropped when users or groups are changed, packages are installed or
+// commands and scripts are run
+type idCache struct {
+	mu     sync.Mutex
+	users  map[string]passwdEntry
+	groups map[string]groupEntry
+}
+
+// loadIDs reads the passwd and group databases when they are not cached, the
+// caller holds the lock
+func (r *Remote) loadIDs() error {
+	if r.ids.users != nil {
+		return nil
+	}
+
+	res, err := r.run("getent passwd", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return errors.Errorf("could not read users: %v %s", err, res.Stderr.String())
+	}
+
+	users := map[string]passwdEntry{}
+	for _, line := range splitLines(res.Stdout.String()) {
+		entry, err := parsePasswd(line)
+		if err != nil {
+			return err
+		}
+		users[entry.name] = entry
+	}
+
+	res, err = r.run("getent group", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return errors.Errorf("could not read groups: %v %s", err, res.Stderr.String())
+	}
+
+	groups := map[string]groupEntry{}
+	for _, line := range splitLines(res.Stdout.String()) {
+		entry, err := parseGroup(line)
+		if err != nil {
+			return err
+		}
+		groups[entry.name] = entry
+	}
+
+	r.ids.users, r.ids.groups = users, groups
+	return nil
+}
+
+// getentKey returns the entry of key in the database db, it's empty when the key
+// does not exist. Directories like LDAP or SSSD may not list their entries
+// without a key, so missing entries are looked up by key.
+func (r *Remote) getentKey(db, key string) (string, error) {
+	res, err := r.run(fmt.Sprintf("getent %s %s", db, shellQuote(key)), bytes.NewBufferString(""))
+	if err != nil {
+		return "", errors.Wrapf(err, "could not look up %s in %s", key, db)
+	}
+
+	// getent exits with 2 when the key is not found
+	if res.ExitStatus == 2 {
+		return "", nil
+	}
+
+	if !res.Success() {
+		return "", errors.Errorf("could not look up %s in %s: %s", key, db, res.Stderr.String())
+	}
+
+	lines := splitLines(strings.TrimSpace(res.Stdout.String()))
+	if len(lines) == 0 {
+		return "", nil
+	}
+
+	return lines[0], nil
+}
+
+// getentUser looks up the passwd entry of key and caches it, the caller holds
+// the lock
+func (r *Remote) getentUser(key string) (*passwdEntry, error) {
+	line, err := r.getentKey("passwd", key)
+	if err != nil || line == "" {
+		return nil, err
+	}
+
+	entry, err := parsePasswd(line)
+	if err != nil {
+		return nil, err
+	}
+
+	r.ids.users[entry.name] = entry
+	return &entry, nil
+}
+
+// resetIDs drops the cached databases
+func (r *Remote) resetIDs() {
+	r.ids.mu.Lock()
+	defer r.ids.mu.Unlock()
+
+	r.ids.users, r.ids.groups = nil, nil
+}
+
+// lookupUser returns the passwd entry of name, or nil when the user does not exist
+func (r *Remote) lookupUser(name string) (*passwdEntry, error) {
+	r.ids.mu.Lock()
+	defer r.ids.mu.Unlock()
+
+	err := r.loadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := r.ids.users[name]
+	if !ok {
+		return r.getentUser(name)
+	}
+
+	return &entry, nil
+}
+
+// lookupGroup returns the group entry of name, or nil when the group does not exist
+func (r *Remote) lookupGroup(name string) (*groupEntry, error) {
+	r.ids.mu.Lock()
+	defer r.ids.mu.Unlock()
+
+	err := r.loadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := r.ids.groups[name]
+	if ok {
+		return &entry, nil
+	}
+
+	line, err := r.getentKey("group", name)
+	if err != nil || line == "" {
+		return nil, err
+	}
+
+	entry, err = parseGroup(line)
+	if err != nil {
+		return nil, err
+	}
+
+	r.ids.groups[entry.name] = entry
+	return &entry, nil
+}
+
+// memberOf returns the supplementary groups of the user name
+func (r *Remote) memberOf(name string) ([]string, error) {
+	r.ids.mu.Lock()
+	defer r.ids.mu.Unlock()
+
+	err := r.loadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	groups := []string{}
+	for _, g := range r.ids.groups {
+		for _, member := range g.members {
+			if member == name {
+				groups = append(groups, g.name)
+			}
+		}
+	}
+
+	return groups, nil
+}
+
+// lookupUID returns the passwd entry of uid, or nil when no user has the uid
+func (r *Remote) lookupUID(uid int) (*passwdEntry, error) {
+	r.ids.mu.Lock()
+	defer r.ids.mu.Unlock()
+
+	err := r.loadIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range r.ids.users {
+		if entry.uid == uid {
+			return &entry, nil
+		}
+	}
+
+	return r.getentUser(strconv.Itoa(uid))
+}
+
+// ownerIDs resolves the uid of owner and the gid of group on the remote, numeric
+// ids are used as they are. The group defaults to the primary group of owner.
+func (r *Remote) ownerIDs(owner, group string) (int, int, error) {
+	var user *passwdEntry
+
+	uid, err := strconv.Atoi(owner)
+	if err != nil {
+		user, err = r.lookupUser(owner)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if user == nil {
+			return 0, 0, errors.Errorf("user %s does not exist", owner)
+		}
+		uid = user.uid
+	}
+
+	if group == "" {
+		if user == nil {
+			user, err = r.lookupUID(uid)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			if user == nil {
+				return 0, 0, errors.Errorf("uid %d has no user, the group has to be set", uid)
+			}
+		}
+
+		return uid, user.gid, nil
+	}
+
+	gid, err := r.groupID(group)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return uid, gid, nil
+}
+
+// groupID resolves the gid of group on the remote, numeric ids are used as they are
+func (r *Remote) groupID(group string) (int, error) {
+	gid, err := strconv.Atoi(group)
+	if err == nil {
+		return gid, nil
+	}
+
+	g, err := r.lookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+
+	if g == nil {
+		return 0, errors.Errorf("group %s does not exist", group)
+	}
+
+	return g.gid, nil
+}
+
+// ownerOf resolves owner and group like ownerIDs, -1 is returned for the uid
+// when no owner is set and for both when neither is set, so chown keeps them
+func (r *Remote) ownerOf(owner, group string) (int, int, error) {
+	if owner == "" {
+		if group == "" {
+			return -1, -1, nil
+		}
+
+		gid, err := r.groupID(group)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		return -1, gid, nil
+	}
+
+	return r.ownerIDs(owner, group)
+}
+
+// fileOwner resolves the owner of f, the numeric UID and GID win over the
+// Owner and Group names
+func (r *Remote) fileOwner(f types.File) (int, int, error) {
+	owner, group := f.Owner, f.Group
+	if f.UID != nil {
+		owner = strconv.Itoa(*f.UID)
+	}
+
+	if f.GID != nil {
+		group = strconv.Itoa(*f.GID)
+	}
+
+	return r.ownerOf(owner, group)
+}
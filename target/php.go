@@ -0,0 +1,230 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// the SAPIs of PHP with their own php.ini
+const (
+	SAPIApache2 = "apache2"
+	SAPIFPM     = "fpm"
+	SAPICLI     = "cli"
+)
+
+// phpVersionCmd prints the version of the php binary, or the versions
+// installed in /etc/php when there is no php binary, e.g. with only fpm
+const phpVersionCmd = `php -r 'echo PHP_MAJOR_VERSION.".".PHP_MINOR_VERSION;' 2>/dev/null || ls /etc/php 2>/dev/null`
+
+var versionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+$`)
+
+// parsePHPVersion reads the output of phpVersionCmd, it fails when no or
+// several versions are found
+func parsePHPVersion(out string) (string, error) {
+	versions := []string{}
+	for _, field := range strings.Fields(out) {
+		if versionPattern.MatchString(field) {
+			versions = append(versions, field)
+		}
+	}
+
+	switch len(versions) {
+	case 0:
+		return "", errors.New("PHP is not installed")
+	case 1:
+		return versions[0], nil
+	}
+
+	return "", fmt.Errorf("PHP versions %s are installed, the version has to be set", strings.Join(versions, ", "))
+}
+
+// detectPHPVersion returns the PHP version of the target, it's detected once
+func (r *Remote) detectPHPVersion() (string, error) {
+	if r.phpVersion != "" {
+		return r.phpVersion, nil
+	}
+
+	res, err := r.run(phpVersionCmd, bytes.NewBufferString(""))
+	if err != nil {
+		return "", errors.Wrap(err, "could not detect PHP version")
+	}
+
+	r.phpVersion, err = parsePHPVersion(res.Stdout.String())
+	return r.phpVersion, err
+}
+
+// phpService returns the service which loads the php.ini of sapi, the cli has none
+func phpService(sapi, version string) string {
+	switch sapi {
+	case SAPIApache2:
+		return "apache2"
+	case SAPIFPM:
+		return fmt.Sprintf("php%s-fpm", version)
+	}
+
+	return ""
+}
+
+// iniKey returns the key of a key = value line and if it's commented out
+func iniKey(line string) (string, bool, bool) {
+	t := strings.TrimSpace(line)
+
+	commented := strings.HasPrefix(t, ";")
+	if commented {
+		t = strings.TrimSpace(strings.TrimPrefix(t, ";"))
+	}
+
+	i := strings.Index(t, "=")
+	if i <= 0 {
+		return "", false, false
+	}
+
+	key := strings.TrimSpace(t[:i])
+	if strings.ContainsAny(key, " \t") {
+		return "", false, false
+	}
+
+	return key, commented, true
+}
+
+// iniValue returns the unquoted value of a key = value line
+func iniValue(line string) string {
+	i := strings.Index(line, "=")
+	return strings.Trim(strings.TrimSpace(line[i+1:]), `"`)
+}
+
+// editIni sets the settings in section of an ini file. Existing values are
+// replaced, commented defaults are kept and the setting is added after them,
+// otherwise at the end of the section. A missing section is appended.
+func editIni(content, section string, settings map[string]string) string {
+	lines := splitLines(content)
+	header := "[" + section + "]"
+
+	start, end := -1, len(lines)
+	for i, line := range lines {
+		t := strings.TrimSpace(line)
+		if start < 0 {
+			if strings.EqualFold(t, header) {
+				start = i
+			}
+			continue
+		}
+
+		if strings.HasPrefix(t, "[") {
+			end = i
+			break
+		}
+	}
+
+	if start < 0 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, header)
+		start, end = len(lines)-1, len(lines)
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := settings[key]
+		setting := key + " = " + value
+
+		active, commented := -1, -1
+		for i := start + 1; i < end; i++ {
+			k, isComment, ok := iniKey(lines[i])
+			if !ok || k != key {
+				continue
+			}
+
+			if isComment {
+				commented = i
+			} else {
+				active = i
+			}
+		}
+
+		if active >= 0 {
+			if iniValue(lines[active]) != strings.Trim(value, `"`) {
+				lines[active] = setting
+			}
+			continue
+		}
+
+		at := commented + 1
+		if commented < 0 {
+			// after the last line of the section which is not empty
+			at = start + 1
+			for i := start + 1; i < end; i++ {
+				if strings.TrimSpace(lines[i]) != "" {
+					at = i + 1
+				}
+			}
+		}
+
+		lines = append(lines[:at], append([]string{setting}, lines[at:]...)...)
+		end++
+	}
+
+	return joinLines(lines)
+}
+
+// EnsurePHPIni sets the values in the php.ini of the PHP version and SAPI. It
+// returns the service which has to be reloaded for the values to take effect.
+func (r *Remote) EnsurePHPIni(ctx context.Context, p types.PHPIni) (types.StatusCode, string, error) {
+	sapi := p.SAPI
+	if sapi == "" {
+		sapi = SAPIApache2
+	}
+
+	switch sapi {
+	case SAPIApache2, SAPIFPM, SAPICLI:
+	default:
+		return types.StatusFailed, "", fmt.Errorf("unknown PHP SAPI %s", sapi)
+	}
+
+	// the version is needed for the path and the name of the fpm service
+	version := p.Version
+	if version == "" && (p.Path == "" || sapi == SAPIFPM) {
+		var err error
+		version, err = r.detectPHPVersion()
+		if err != nil {
+			return types.StatusFailed, "", err
+		}
+	}
+
+	iniPath := p.Path
+	if iniPath == "" {
+		iniPath = fmt.Sprintf("/etc/php/%s/%s/php.ini", version, sapi)
+	}
+
+	section := p.Section
+	if section == "" {
+		section = "PHP"
+	}
+
+	changed, err := r.editFile(iniPath, false, func(content string) (string, error) {
+		return editIni(content, section, p.Settings), nil
+	})
+	if err != nil {
+		return types.StatusFailed, "", err
+	}
+
+	if !changed {
+		return types.StatusSatisfied, "", nil
+	}
+
+	fmt.Printf("%s is updated on %s\n", iniPath, r.addr)
+	return types.StatusEnforced, phpService(sapi, version), nil
+}
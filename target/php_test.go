@@ -0,0 +1,86 @@
+package target
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePHPVersion(t *testing.T) {
+	version, err := parsePHPVersion("8.1")
+	if err != nil || version != "8.1" {
+		t.Errorf("expected %v and got %v", "8.1", version)
+	}
+
+	// ls /etc/php without the php binary
+	version, err = parsePHPVersion("7.4\n")
+	if err != nil || version != "7.4" {
+		t.Errorf("expected %v and got %v", "7.4", version)
+	}
+
+	for _, out := range []string{"", "test", "7.4\n8.1\n"} {
+		_, err := parsePHPVersion(out)
+		if err == nil {
+			t.Errorf("%s: expected error and got nil", out)
+		}
+	}
+
+	if s := phpService(SAPIFPM, "8.1"); s != "php8.1-fpm" {
+		t.Errorf("expected %v and got %v", "php8.1-fpm", s)
+	}
+
+	if s := phpService(SAPICLI, "8.1"); s != "" {
+		t.Errorf("expected %v and got %v", "", s)
+	}
+}
+
+func TestEditIni(t *testing.T) {
+	ini := `[PHP]
+; Maximum amount of memory a script may consume
+; http://php.net/memory-limit
+memory_limit = 128M
+
+; Maximum allowed size for uploaded files.
+;upload_max_filesize = 2M
+
+[Session]
+session.save_handler = files
+`
+
+	content := editIni(ini, "PHP", map[string]string{
+		"memory_limit":        "256M",
+		"upload_max_filesize": "64M",
+		"post_max_size":       "64M",
+	})
+
+	expected := `[PHP]
+; Maximum amount of memory a script may consume
+; http://php.net/memory-limit
+memory_limit = 256M
+
+; Maximum allowed size for uploaded files.
+;upload_max_filesize = 2M
+upload_max_filesize = 64M
+post_max_size = 64M
+
+[Session]
+session.save_handler = files
+`
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	// the values are set, so nothing changes
+	if again := editIni(content, "PHP", map[string]string{"memory_limit": "256M"}); again != content {
+		t.Errorf("expected %q and got %q", content, again)
+	}
+
+	content = editIni(ini, "opcache", map[string]string{"opcache.enable": "1"})
+	if content != ini+"\n[opcache]\nopcache.enable = 1\n" {
+		t.Errorf("expected %q and got %q", ini+"\n[opcache]\nopcache.enable = 1\n", content)
+	}
+
+	content = editIni(ini, "Session", map[string]string{"session.save_handler": "redis"})
+	if content != strings.Replace(ini, "session.save_handler = files", "session.save_handler = redis", 1) {
+		t.Errorf("expected %v and got %q", "save_handler redis", content)
+	}
+}
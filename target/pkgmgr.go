@@ -0,0 +1,347 @@
+package target
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// PackageManager builds the commands needed to query, install and remove
+// packages on a target and parses the query results
+type PackageManager interface {
+	Name() string
+
+	// Query returns the cmd which reports the state of pkg
+	Query(pkg string) string
+
+	// Parse reads the response of the Query cmd and returns whether the package
+	// is installed and its version
+	Parse(res types.Response) (types.Status, string)
+
+	// Install returns the cmd installing pkg, version is optional
+	Install(pkg, version string) string
+	Remove(pkg string) string
+
+	// Held returns the cmd which exits with 0 when pkg is held
+	Held(pkg string) string
+
+	// Hold returns the cmd holding or releasing pkg
+	Hold(pkg string, hold bool) string
+}
+
+// packageManagers holds the supported package managers by name
+var packageManagers = map[string]PackageManager{
+	"apt": apt{},
+	"dnf": rpm{name: "dnf"},
+	"yum": rpm{name: "yum"},
+	"apk": apk{},
+}
+
+// defaultLockTimeout is the seconds apt waits for the dpkg lock
+const defaultLockTimeout = 300
+
+// apt manages packages on debian based distributions
+type apt struct {
+	opts types.Apt
+}
+
+func (apt) Name() string {
+	return "apt"
+}
+
+func (apt) Query(pkg string) string {
+	return fmt.Sprintf(`dpkg-query -f '${Package}\t${db:Status-Abbrev}\t${Version}\t${Name}' -W %s`, shellQuote(pkg))
+}
+
+func (apt) Parse(res types.Response) (types.Status, string) {
+	if !res.Success() {
+		return types.StatusNotInstalled, ""
+	}
+
+	// the package info has been returned, so we get the status byte
+	stdOutarr := strings.Split(res.Stdout.String(), "\t")
+	if len(stdOutarr) < 3 || len(stdOutarr[1]) < 2 || stdOutarr[1][1] != types.StatusInstalled {
+		return types.StatusNotInstalled, ""
+	}
+
+	return types.StatusInstalled, stdOutarr[2]
+}
+
+// aptGet returns apt-get running without prompts, waiting for the dpkg lock
+// and handling modified config files with the configured policy
+func (m apt) aptGet() string {
+	timeout := m.opts.LockTimeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+
+	conffile := "-o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold"
+	if m.opts.Conffile == "new" {
+		conffile = "-o Dpkg::Options::=--force-confnew"
+	}
+
+	return fmt.Sprintf("DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=%d %s", timeout, conffile)
+}
+
+func (m apt) Install(pkg, version string) string {
+	if version == "" {
+		return fmt.Sprintf("%s install %s", m.aptGet(), shellQuote(pkg))
+	}
+
+	// held packages can only be moved to the pinned version explicitly, apt
+	// matches the globs of the version itself
+	return fmt.Sprintf("%s --allow-downgrades --allow-change-held-packages install %s", m.aptGet(), shellQuote(pkg+"="+version))
+}
+
+// on purge remove dependencies
+func (m apt) Remove(pkg string) string {
+	return fmt.Sprintf("%s purge %s && %s autoremove", m.aptGet(), shellQuote(pkg), m.aptGet())
+}
+
+// Update returns the cmd updating the apt cache
+func (m apt) Update() string {
+	return fmt.Sprintf("%s update", m.aptGet())
+}
+
+// Preseed returns the cmd setting the debconf values read from stdin and the stdin
+func (m apt) Preseed() (string, string) {
+	var b strings.Builder
+	for _, v := range m.opts.Preseed {
+		fmt.Fprintf(&b, "%s %s %s %s\n", v.Package, v.Question, v.Type, v.Value)
+	}
+
+	return "debconf-set-selections", b.String()
+}
+
+func (apt) Held(pkg string) string {
+	return fmt.Sprintf("apt-mark showhold %s | grep -qxF %s", shellQuote(pkg), shellQuote(pkg))
+}
+
+func (apt) Hold(pkg string, hold bool) string {
+	if hold {
+		return "apt-mark hold " + shellQuote(pkg)
+	}
+
+	return "apt-mark unhold " + shellQuote(pkg)
+}
+
+// rpm manages packages on red hat based distributions using dnf or yum
+type rpm struct {
+	name string
+}
+
+func (m rpm) Name() string {
+	return m.name
+}
+
+func (rpm) Query(pkg string) string {
+	return fmt.Sprintf(`rpm -q --qf '%%{NAME}\t%%{VERSION}-%%{RELEASE}\n' %s`, shellQuote(pkg))
+}
+
+func (rpm) Parse(res types.Response) (types.Status, string) {
+	if !res.Success() {
+		return types.StatusNotInstalled, ""
+	}
+
+	stdOutarr := strings.Split(strings.TrimSpace(res.Stdout.String()), "\t")
+	if len(stdOutarr) < 2 {
+		return types.StatusNotInstalled, ""
+	}
+
+	return types.StatusInstalled, stdOutarr[1]
+}
+
+func (m rpm) Install(pkg, version string) string {
+	if version == "" {
+		return fmt.Sprintf("%s install -y %s", m.name, shellQuote(pkg))
+	}
+
+	// dnf and yum match the globs of the version themselves
+	return fmt.Sprintf("%s install -y %s", m.name, shellQuote(pkg+"-"+version))
+}
+
+func (m rpm) Remove(pkg string) string {
+	return fmt.Sprintf("%s remove -y %s", m.name, shellQuote(pkg))
+}
+
+// holds are managed with the versionlock plugin
+func (m rpm) Held(pkg string) string {
+	return fmt.Sprintf("%s versionlock list | grep -q %s", m.name, shellQuote("^"+regexp.QuoteMeta(pkg)+"-[0-9]*:"))
+}
+
+func (m rpm) Hold(pkg string, hold bool) string {
+	if hold {
+		return fmt.Sprintf("%s versionlock add %s", m.name, shellQuote(pkg))
+	}
+
+	return fmt.Sprintf("%s versionlock delete %s", m.name, shellQuote(pkg))
+}
+
+// apk manages packages on alpine
+type apk struct{}
+
+func (apk) Name() string {
+	return "apk"
+}
+
+func (apk) Query(pkg string) string {
+	return "apk info -e -v " + shellQuote(pkg)
+}
+
+// Parse reads `apk info -e -v` output which is the installed <name>-<version>
+func (apk) Parse(res types.Response) (types.Status, string) {
+	out := strings.TrimSpace(res.Stdout.String())
+	if !res.Success() || out == "" {
+		return types.StatusNotInstalled, ""
+	}
+
+	_, version := splitAPKVersion(out)
+	return types.StatusInstalled, version
+}
+
+// splitAPKVersion splits <name>-<version> as listed by apk. apk versions always
+// end with -r<release>, so the version starts at the second last dash
+func splitAPKVersion(pkg string) (string, string) {
+	parts := strings.Split(pkg, "-")
+	if len(parts) < 3 {
+		return pkg, ""
+	}
+
+	return strings.Join(parts[:len(parts)-2], "-"), strings.Join(parts[len(parts)-2:], "-")
+}
+
+func (apk) Install(pkg, version string) string {
+	if version == "" {
+		return "apk add " + shellQuote(pkg)
+	}
+
+	// apk has no globs, a version with a glob is matched as prefix with ~
+	if i := strings.IndexAny(version, "*?["); i >= 0 {
+		return "apk add " + shellQuote(pkg+"~"+version[:i])
+	}
+
+	return "apk add " + shellQuote(pkg+"="+version)
+}
+
+func (apk) Remove(pkg string) string {
+	return "apk del " + shellQuote(pkg)
+}
+
+// apk pins packages through the version in /etc/apk/world
+func (apk) Held(pkg string) string {
+	return fmt.Sprintf("grep -q %s /etc/apk/world", shellQuote("^"+regexp.QuoteMeta(pkg)+"="))
+}
+
+func (apk) Hold(pkg string, hold bool) string {
+	if hold {
+		return fmt.Sprintf(`apk add %s"$(apk info -e -v %s | sed %s)"`, shellQuote(pkg+"="), shellQuote(pkg), shellQuote("s/^"+regexp.QuoteMeta(pkg)+"-//"))
+	}
+
+	return "apk add " + shellQuote(pkg)
+}
+
+// SetPackageManager selects the package manager by name instead of detecting it.
+// An empty name keeps the detection.
+func (r *Remote) SetPackageManager(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	pm, ok := packageManagers[name]
+	if !ok {
+		return fmt.Errorf("unknown package manager %s", name)
+	}
+
+	r.pkgmgr = r.withOptions(pm)
+	return nil
+}
+
+// SetAptOptions sets the options used when the package manager is apt
+func (r *Remote) SetAptOptions(opts types.Apt) error {
+	switch opts.Conffile {
+	case "", "keep", "new":
+	default:
+		return fmt.Errorf("unknown conffile policy %q, use keep or new", opts.Conffile)
+	}
+
+	r.aptOptions = opts
+	if r.pkgmgr != nil {
+		r.pkgmgr = r.withOptions(r.pkgmgr)
+	}
+
+	return nil
+}
+
+// withOptions returns pm configured with the options of the Remote
+func (r *Remote) withOptions(pm PackageManager) PackageManager {
+	if a, ok := pm.(apt); ok {
+		a.opts = r.aptOptions
+		return a
+	}
+
+	return pm
+}
+
+// packageManager returns the package manager of the target
+// if it's not set, it will be detected from /etc/os-release
+func (r *Remote) packageManager() (PackageManager, error) {
+	if r.pkgmgr != nil {
+		return r.pkgmgr, nil
+	}
+
+	res, err := r.run("cat /etc/os-release", bytes.NewBufferString(""))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not detect package manager")
+	}
+
+	r.pkgmgr = r.withOptions(detectPackageManager(parseOSRelease(res.Stdout.String())))
+	return r.pkgmgr, nil
+}
+
+// parseOSRelease parses the KEY=value pairs of /etc/os-release
+func parseOSRelease(content string) map[string]string {
+	release := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		i := strings.Index(line, "=")
+		if i < 0 {
+			continue
+		}
+
+		release[line[:i]] = strings.Trim(line[i+1:], `"'`)
+	}
+
+	return release
+}
+
+// detectPackageManager picks the package manager matching the distribution ID
+// or one of the distributions it's like. apt is used when nothing matches, as it
+// was the only supported package manager.
+func detectPackageManager(release map[string]string) PackageManager {
+	ids := append([]string{release["ID"]}, strings.Fields(release["ID_LIKE"])...)
+
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return packageManagers["apt"]
+		case "alpine":
+			return packageManagers["apk"]
+		case "rhel", "centos", "fedora", "rocky", "almalinux":
+			// dnf replaced yum starting from EL 8
+			if release["ID"] != "fedora" && strings.HasPrefix(release["VERSION_ID"], "7") {
+				return packageManagers["yum"]
+			}
+			return packageManagers["dnf"]
+		}
+	}
+
+	return packageManagers["apt"]
+}
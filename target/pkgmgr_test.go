@@ -0,0 +1,153 @@
+package target
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestDetectPackageManager(t *testing.T) {
+	cases := map[string]string{
+		"ID=ubuntu\nID_LIKE=debian\nVERSION_ID=\"22.04\"":                  "apt",
+		"ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\nVERSION_ID=\"9.1\"": "dnf",
+		"ID=\"centos\"\nID_LIKE=\"rhel fedora\"\nVERSION_ID=\"7\"":         "yum",
+		"ID=alpine\nVERSION_ID=3.17.0":                                     "apk",
+		"test":                                                             "apt",
+	}
+
+	for content, expected := range cases {
+		pm := detectPackageManager(parseOSRelease(content))
+		if pm.Name() != expected {
+			t.Errorf("expected %v and got %v", expected, pm.Name())
+		}
+	}
+}
+
+func TestPackageManagerParse(t *testing.T) {
+	cases := []struct {
+		pm      PackageManager
+		stdout  string
+		exit    int
+		status  types.Status
+		version string
+	}{
+		{apt{}, "php\tii \t2:8.1+92ubuntu1\tphp", 0, types.StatusInstalled, "2:8.1+92ubuntu1"},
+		{apt{}, "php\trc \t2:8.1+92ubuntu1\tphp", 0, types.StatusNotInstalled, ""},
+		{apt{}, "", 1, types.StatusNotInstalled, ""},
+		{rpm{name: "dnf"}, "php\t8.0.30-1.el9\n", 0, types.StatusInstalled, "8.0.30-1.el9"},
+		{rpm{name: "dnf"}, "package php is not installed\n", 1, types.StatusNotInstalled, ""},
+		{apk{}, "php81-8.1.16-r0\n", 0, types.StatusInstalled, "8.1.16-r0"},
+		{apk{}, "", 1, types.StatusNotInstalled, ""},
+	}
+
+	for _, c := range cases {
+		res := types.Response{ExitStatus: c.exit, Stdout: *bytes.NewBufferString(c.stdout)}
+		status, version := c.pm.Parse(res)
+		if status != c.status {
+			t.Errorf("%s: expected %v and got %v", c.pm.Name(), c.status, status)
+		}
+
+		if version != c.version {
+			t.Errorf("%s: expected %v and got %v", c.pm.Name(), c.version, version)
+		}
+	}
+}
+
+func TestSetPackageManager(t *testing.T) {
+	r := Remote{}
+	err := r.SetPackageManager("pacman")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	err = r.SetPackageManager("dnf")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	pm, err := r.packageManager()
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if pm.Name() != "dnf" {
+		t.Errorf("expected %v and got %v", "dnf", pm.Name())
+	}
+}
+
+func TestMatchVersion(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{"2:8.1+92ubuntu1", "2:8.1+92ubuntu1", true},
+		{"2:8.1*", "2:8.1+92ubuntu1", true},
+		{"8.1.*", "8.2.1-1", false},
+		{"[8.1", "[8.1", true},
+	}
+
+	for _, c := range cases {
+		if matchVersion(c.constraint, c.version) != c.expected {
+			t.Errorf("%s %s: expected %v and got %v", c.constraint, c.version, c.expected, !c.expected)
+		}
+	}
+}
+
+func TestPackageManagerInstall(t *testing.T) {
+	cases := map[string]string{
+		apt{}.Install("php", ""):                  "DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=300 -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold install 'php'",
+		apt{}.Install("php", "2:8.1*"):            "DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=300 -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold --allow-downgrades --allow-change-held-packages install 'php=2:8.1*'",
+		rpm{name: "dnf"}.Install("php", "8.0.30"): "dnf install -y 'php-8.0.30'",
+		rpm{name: "dnf"}.Install("php", "8.1*"):   "dnf install -y 'php-8.1*'",
+		apk{}.Install("php81", "8.1.16-r0"):       "apk add 'php81=8.1.16-r0'",
+		apk{}.Install("php81", "8.1*"):            "apk add 'php81~8.1'",
+		apk{}.Hold("php81", true):                 `apk add 'php81='"$(apk info -e -v 'php81' | sed 's/^php81-//')"`,
+		apt{}.Hold("php", false):                  "apt-mark unhold 'php'",
+		apt{}.Remove("php; reboot"):               "DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=300 -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold purge 'php; reboot' && DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=300 -o Dpkg::Options::=--force-confdef -o Dpkg::Options::=--force-confold autoremove",
+	}
+
+	for cmd, expected := range cases {
+		if cmd != expected {
+			t.Errorf("expected %v and got %v", expected, cmd)
+		}
+	}
+}
+
+func TestAptOptions(t *testing.T) {
+	r := Remote{}
+	for _, conffile := range []string{"keep ", "replace"} {
+		err := r.SetAptOptions(types.Apt{Conffile: conffile})
+		if err == nil {
+			t.Errorf("%q: expected error and got nil", conffile)
+		}
+	}
+
+	err := r.SetAptOptions(types.Apt{
+		Conffile:    "new",
+		LockTimeout: 60,
+		Preseed: []types.Debconf{
+			{Package: "tzdata", Question: "tzdata/Areas", Type: "select", Value: "Europe"},
+		},
+	})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	err = r.SetPackageManager("apt")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	pm, _ := r.packageManager()
+	expected := "DEBIAN_FRONTEND=noninteractive apt-get -y -o DPkg::Lock::Timeout=60 -o Dpkg::Options::=--force-confnew update"
+	if pm.(apt).Update() != expected {
+		t.Errorf("expected %v and got %v", expected, pm.(apt).Update())
+	}
+
+	cmd, stdin := pm.(apt).Preseed()
+	if cmd != "debconf-set-selections" || stdin != "tzdata tzdata/Areas select Europe\n" {
+		t.Errorf("expected %v and got %v %v", "tzdata preseed", cmd, stdin)
+	}
+}
@@ -0,0 +1,70 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// rebootRequiredFile is created by packages which need a reboot on debian based distributions
+const rebootRequiredFile = "/var/run/reboot-required"
+
+// bootIDFile holds the random id of the current boot, it changes with every boot
+const bootIDFile = "/proc/sys/kernel/random/boot_id"
+
+// rebootCmd reboots the target in the background, so the cmd returns before
+// the connection is dropped. sudo does not prompt for a password, the check of
+// sudoCheckCmd is done in the foreground before.
+const (
+	sudoCheckCmd = "sudo -n true"
+	rebootCmd    = "sudo -n nohup sh -c 'sleep 2 && reboot' >/dev/null 2>&1 &"
+)
+
+// RebootRequired checks if the target needs a reboot, e.g. after a kernel upgrade
+func (r *Remote) RebootRequired(ctx context.Context) (bool, error) {
+	res, err := r.run("test -f "+rebootRequiredFile, bytes.NewBufferString(""))
+	if err != nil {
+		return false, errors.Wrap(err, "could not check if a reboot is required")
+	}
+
+	return res.Success(), nil
+}
+
+// BootID returns the id of the current boot of the target, a changed id tells
+// that the target has rebooted
+func (r *Remote) BootID(ctx context.Context) (string, error) {
+	res, err := r.run("cat "+bootIDFile, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return "", errors.Errorf("could not read the boot id of %s: %v %s", r.addr, err, res.Stderr.String())
+	}
+
+	id := strings.TrimSpace(res.Stdout.String())
+	if id == "" {
+		return "", errors.Errorf("empty boot id on %s", r.addr)
+	}
+
+	return id, nil
+}
+
+// Reboot reboots the target and closes the connections, a new Remote is needed
+// once the target is back
+func (r *Remote) Reboot(ctx context.Context) error {
+	fmt.Printf("trying to reboot %s ...\n", r.addr)
+
+	// the reboot runs in the background and always succeeds, so sudo is
+	// checked before
+	res, err := r.run(sudoCheckCmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return errors.Errorf("could not reboot %s, sudo needs a password: %v %s", r.addr, err, res.Stderr.String())
+	}
+
+	res, err = r.run(rebootCmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return errors.Errorf("could not reboot %s: %v %s", r.addr, err, res.Stderr.String())
+	}
+
+	return r.Close()
+}
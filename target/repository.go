@@ -0,0 +1,230 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+var (
+	// sourcesDir holds the managed apt sources
+	sourcesDir = "/etc/apt/sources.list.d"
+
+	// keyringsDir holds the signing keys of the managed apt sources
+	keyringsDir = "/etc/apt/keyrings"
+)
+
+// repositoryKeyPath returns the remote path of the signing key, apt requires
+// armored keys to have the .asc extension
+func repositoryKeyPath(name string, key []byte) string {
+	if bytes.HasPrefix(bytes.TrimSpace(key), []byte("-----BEGIN PGP")) {
+		return path.Join(keyringsDir, name+".asc")
+	}
+
+	return path.Join(keyringsDir, name+".gpg")
+}
+
+// renderRepository returns the remote path and content of the sources file
+// keyPath is the remote signing key, it's optional
+func renderRepository(repo types.Repository, keyPath string) (string, []byte, error) {
+	if repo.Name == "" || len(repo.URIs) == 0 || len(repo.Suites) == 0 {
+		return "", nil, fmt.Errorf("repository %s needs a name, uris and suites", repo.Name)
+	}
+
+	repoTypes := repo.Types
+	if len(repoTypes) == 0 {
+		repoTypes = []string{"deb"}
+	}
+
+	var b strings.Builder
+
+	switch repo.Format {
+	case "", "deb822":
+		fmt.Fprintf(&b, "Types: %s\n", strings.Join(repoTypes, " "))
+		fmt.Fprintf(&b, "URIs: %s\n", strings.Join(repo.URIs, " "))
+		fmt.Fprintf(&b, "Suites: %s\n", strings.Join(repo.Suites, " "))
+		if len(repo.Components) > 0 {
+			fmt.Fprintf(&b, "Components: %s\n", strings.Join(repo.Components, " "))
+		}
+		if len(repo.Architectures) > 0 {
+			fmt.Fprintf(&b, "Architectures: %s\n", strings.Join(repo.Architectures, " "))
+		}
+		if keyPath != "" {
+			fmt.Fprintf(&b, "Signed-By: %s\n", keyPath)
+		}
+
+		return path.Join(sourcesDir, repo.Name+".sources"), []byte(b.String()), nil
+
+	case "list":
+		options := []string{}
+		if len(repo.Architectures) > 0 {
+			options = append(options, "arch="+strings.Join(repo.Architectures, ","))
+		}
+		if keyPath != "" {
+			options = append(options, "signed-by="+keyPath)
+		}
+
+		opts := ""
+		if len(options) > 0 {
+			opts = fmt.Sprintf(" [%s]", strings.Join(options, " "))
+		}
+
+		for _, t := range repoTypes {
+			for _, uri := range repo.URIs {
+				for _, suite := range repo.Suites {
+					line := strings.Join(append([]string{t + opts, uri, suite}, repo.Components...), " ")
+					fmt.Fprintln(&b, line)
+				}
+			}
+		}
+
+		return path.Join(sourcesDir, repo.Name+".list"), []byte(b.String()), nil
+	}
+
+	return "", nil, fmt.Errorf("unknown format %s for repository %s", repo.Format, repo.Name)
+}
+
+// ensureRepository writes the signing key and the sources file of repo
+// it returns true if any of them changed
+func (r *Remote) ensureRepository(repo types.Repository) (bool, error) {
+	var keyPath string
+	var keyChanged bool
+
+	if repo.Key != "" {
+		key, err := os.ReadFile(repo.Key)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to read key %s", repo.Key)
+		}
+
+		sftp, err := r.sftpClient()
+		if err != nil {
+			return false, errors.Wrap(err, "could not get sftp client")
+		}
+
+		err = sftp.MkdirAll(keyringsDir)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to create %s", keyringsDir)
+		}
+
+		keyPath = repositoryKeyPath(repo.Name, key)
+		keyChanged, err = r.writeFile(keyPath, key, 0644)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	sourcePath, content, err := renderRepository(repo, keyPath)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := r.writeFile(sourcePath, content, 0644)
+	if err != nil {
+		return false, err
+	}
+
+	return changed || keyChanged, nil
+}
+
+// Repositories ensures the apt sources and their signing keys are in place
+// it returns true if any of them changed, so the apt cache has to be updated
+func (r *Remote) Repositories(ctx context.Context, repos []types.Repository) (bool, error) {
+	if len(repos) == 0 {
+		return false, nil
+	}
+
+	pm, err := r.packageManager()
+	if err != nil {
+		return false, err
+	}
+
+	if pm.Name() != "apt" {
+		return false, fmt.Errorf("repositories are not supported with %s", pm.Name())
+	}
+
+	changed := false
+	for _, repo := range repos {
+		fmt.Printf("trying to add repository %s on %s ...\n", repo.Name, r.addr)
+
+		repoChanged, err := r.ensureRepository(repo)
+		if err != nil {
+			fmt.Printf("could not add repository %s on %s with err=%v\n", repo.Name, r.addr, err)
+			continue
+		}
+
+		changed = changed || repoChanged
+		fmt.Printf("repository %s is added on %s\n", repo.Name, r.addr)
+	}
+
+	return changed, nil
+}
+
+// parseCacheAge parses the output of the cache age cmd which is the current
+// time followed by the time of the last update, both in unix seconds
+func parseCacheAge(out string) (int64, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("unexpected cache age output %q", out)
+	}
+
+	now, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid current time")
+	}
+
+	updated, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid update time")
+	}
+
+	return now - updated, nil
+}
+
+// UpdateCache runs `apt-get update` when force is set or the cache is older
+// than maxAge seconds. A maxAge of 0 never expires the cache.
+func (r *Remote) UpdateCache(ctx context.Context, force bool, maxAge int) (types.StatusCode, error) {
+	if !force && maxAge <= 0 {
+		return types.StatusSatisfied, nil
+	}
+
+	pm, err := r.packageManager()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	a, ok := pm.(apt)
+	if !ok {
+		return types.StatusSatisfied, nil
+	}
+
+	if !force {
+		cmd := "date +%s; stat -c %Y /var/lib/apt/periodic/update-success-stamp 2>/dev/null || stat -c %Y /var/lib/apt/lists"
+		res, err := r.run(cmd, bytes.NewBufferString(""))
+		if err != nil {
+			return types.StatusFailed, errors.Wrap(err, "could not check apt cache age")
+		}
+
+		// an unknown age is handled as an expired cache
+		age, err := parseCacheAge(res.Stdout.String())
+		if err == nil && age <= int64(maxAge) {
+			return types.StatusSatisfied, nil
+		}
+	}
+
+	fmt.Printf("trying to update apt cache on %s ...\n", r.addr)
+
+	res, err := r.RunCmd(a.Update(), bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not update apt cache: %v %s", err, res.Stderr.String())
+	}
+
+	fmt.Printf("apt cache is updated on %s\n", r.addr)
+	return types.StatusEnforced, nil
+}
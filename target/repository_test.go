@@ -0,0 +1,85 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestRenderRepository(t *testing.T) {
+	repo := types.Repository{
+		Name:       "php",
+		URIs:       []string{"https://packages.sury.org/php/"},
+		Suites:     []string{"bullseye"},
+		Components: []string{"main"},
+	}
+
+	path, content, err := renderRepository(repo, "/etc/apt/keyrings/php.asc")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if path != "/etc/apt/sources.list.d/php.sources" {
+		t.Errorf("expected %v and got %v", "/etc/apt/sources.list.d/php.sources", path)
+	}
+
+	expected := "Types: deb\nURIs: https://packages.sury.org/php/\nSuites: bullseye\nComponents: main\nSigned-By: /etc/apt/keyrings/php.asc\n"
+	if string(content) != expected {
+		t.Errorf("expected %v and got %v", expected, string(content))
+	}
+
+	repo.Format = "list"
+	repo.Architectures = []string{"amd64"}
+	path, content, err = renderRepository(repo, "/etc/apt/keyrings/php.asc")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if path != "/etc/apt/sources.list.d/php.list" {
+		t.Errorf("expected %v and got %v", "/etc/apt/sources.list.d/php.list", path)
+	}
+
+	expected = "deb [arch=amd64 signed-by=/etc/apt/keyrings/php.asc] https://packages.sury.org/php/ bullseye main\n"
+	if string(content) != expected {
+		t.Errorf("expected %v and got %v", expected, string(content))
+	}
+
+	repo.Format = "yaml"
+	_, _, err = renderRepository(repo, "")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	_, _, err = renderRepository(types.Repository{Name: "php"}, "")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
+
+func TestRepositoryKeyPath(t *testing.T) {
+	path := repositoryKeyPath("php", []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n"))
+	if path != "/etc/apt/keyrings/php.asc" {
+		t.Errorf("expected %v and got %v", "/etc/apt/keyrings/php.asc", path)
+	}
+
+	path = repositoryKeyPath("php", []byte{0x99, 0x02})
+	if path != "/etc/apt/keyrings/php.gpg" {
+		t.Errorf("expected %v and got %v", "/etc/apt/keyrings/php.gpg", path)
+	}
+}
+
+func TestParseCacheAge(t *testing.T) {
+	age, err := parseCacheAge("1700003600\n1700000000\n")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if age != 3600 {
+		t.Errorf("expected %v and got %v", 3600, age)
+	}
+
+	_, err = parseCacheAge("test")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}
@@ -0,0 +1,89 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// scriptPath returns a unique temporary remote path for the local script
+func scriptPath(local string) (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate script name")
+	}
+
+	return fmt.Sprintf("/tmp/goconf-%s-%s", hex.EncodeToString(b), path.Base(local)), nil
+}
+
+// RunScript uploads the script, runs it with its args unless a guard
+// short-circuits it and removes it afterwards
+func (r *Remote) RunScript(ctx context.Context, s types.Script) (types.StatusCode, types.Response, error) {
+	skip, reason, err := r.guarded(s.Guards, s.Dir, s.Env, s.User)
+	if err != nil {
+		return types.StatusFailed, types.Response{}, err
+	}
+
+	if skip {
+		res := types.Response{}
+		res.Stdout.WriteString(reason)
+		return types.StatusSatisfied, res, nil
+	}
+
+	remotePath, err := scriptPath(s.Path)
+	if err != nil {
+		return types.StatusFailed, types.Response{}, err
+	}
+
+	// the script is owned by the user running it
+	owner := s.User
+	if owner == "" {
+		owner = r.connuser
+	}
+
+	err = r.Push(ctx, []types.File{
+		{
+			Owner:      owner,
+			Mode:       0700,
+			LocalPath:  s.Path,
+			RemotePath: remotePath,
+		},
+	})
+	if err != nil {
+		return types.StatusFailed, types.Response{}, errors.Wrapf(err, "could not upload %s", s.Path)
+	}
+
+	defer func() {
+		sftp, err := r.sftpClient()
+		if err == nil {
+			sftp.Remove(remotePath)
+		}
+	}()
+
+	cmd := []string{shellQuote(remotePath)}
+	for _, arg := range s.Args {
+		cmd = append(cmd, shellQuote(arg))
+	}
+
+	// the script may add users and groups
+	defer r.resetIDs()
+
+	res, err := r.RunCmd(commandLine(strings.Join(cmd, " "), s.Dir, s.Env, s.User), bytes.NewBufferString(""))
+	if err != nil {
+		return types.StatusFailed, res, errors.Wrapf(err, "could not run %s", s.Path)
+	}
+
+	if !res.Success() {
+		return types.StatusFailed, res, fmt.Errorf("%s exited with %d", s.Path, res.ExitStatus)
+	}
+
+	return types.StatusEnforced, res, nil
+}
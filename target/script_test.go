@@ -0,0 +1,22 @@
+package target
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScriptPath(t *testing.T) {
+	p1, err := scriptPath("server/scripts/provision.sh")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	p2, _ := scriptPath("server/scripts/provision.sh")
+	if p1 == p2 {
+		t.Errorf("expected unique paths and got %v twice", p1)
+	}
+
+	if !strings.HasPrefix(p1, "/tmp/goconf-") || !strings.HasSuffix(p1, "-provision.sh") {
+		t.Errorf("expected %v and got %v", "/tmp/goconf-<random>-provision.sh", p1)
+	}
+}
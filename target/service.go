@@ -0,0 +1,212 @@
+package target
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// unitDirs are the directories systemd loads unit files from
+var unitDirs = []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"}
+
+// serviceState is the current state of a service on the target
+type serviceState struct {
+	active  bool
+	enabled bool
+	masked  bool
+}
+
+// isUnitFile checks if path is a systemd unit file, so a daemon-reload is needed
+// after changing it
+func isUnitFile(remotePath string) bool {
+	for _, dir := range unitDirs {
+		if strings.HasPrefix(path.Clean(remotePath), dir+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasSystemd checks if the target is booted with systemd, otherwise SysV init is used
+func (r *Remote) hasSystemd() (bool, error) {
+	if r.systemd != nil {
+		return *r.systemd, nil
+	}
+
+	res, err := r.run("test -d /run/systemd/system", bytes.NewBufferString(""))
+	if err != nil {
+		return false, errors.Wrap(err, "could not detect init system")
+	}
+
+	systemd := res.Success()
+	r.systemd = &systemd
+	return systemd, nil
+}
+
+// serviceCmd returns the cmd running action on service using the init system of the target
+func (r *Remote) serviceCmd(service, action string) (string, error) {
+	systemd, err := r.hasSystemd()
+	if err != nil {
+		return "", err
+	}
+
+	if systemd {
+		return fmt.Sprintf("sudo systemctl %s %s", action, service), nil
+	}
+
+	switch action {
+	case "enable", "disable":
+		return fmt.Sprintf("sudo update-rc.d %s %s", service, action), nil
+	case "mask", "unmask":
+		return "", fmt.Errorf("%s is not supported without systemd", action)
+	}
+
+	return fmt.Sprintf("sudo service %s %s", service, action), nil
+}
+
+// serviceState queries the current state of service
+func (r *Remote) serviceState(service string) (serviceState, error) {
+	var state serviceState
+
+	systemd, err := r.hasSystemd()
+	if err != nil {
+		return state, err
+	}
+
+	queries := map[*bool]string{
+		&state.active:  fmt.Sprintf("service %s status", service),
+		&state.enabled: fmt.Sprintf("ls /etc/rc[2345].d/S??%s", service),
+	}
+
+	if systemd {
+		queries = map[*bool]string{
+			&state.active:  fmt.Sprintf("systemctl is-active --quiet %s", service),
+			&state.enabled: fmt.Sprintf("systemctl is-enabled --quiet %s", service),
+			&state.masked:  fmt.Sprintf(`test "$(systemctl is-enabled %s)" = masked`, service),
+		}
+	}
+
+	for field, cmd := range queries {
+		res, err := r.run(cmd, bytes.NewBufferString(""))
+		if err != nil {
+			return state, errors.Wrapf(err, "could not query state of %s", service)
+		}
+
+		*field = res.Success()
+	}
+
+	return state, nil
+}
+
+// serviceActions returns the actions needed to move a service from current
+// to the desired state, in the order they have to run
+func serviceActions(s types.Service, current serviceState) ([]string, error) {
+	actions := []string{}
+
+	// a masked unit can not be started or enabled, systemd fails on every run
+	if s.Masked != nil && *s.Masked {
+		switch s.State {
+		case "started", "restarted", "reloaded":
+			return nil, fmt.Errorf("service %s can not be masked and %s", s.Name, s.State)
+		}
+
+		if s.Enabled != nil && *s.Enabled {
+			return nil, fmt.Errorf("service %s can not be masked and enabled", s.Name)
+		}
+	}
+
+	if s.Masked != nil && *s.Masked != current.masked {
+		if *s.Masked {
+			// a masked unit can not be started
+			return []string{"stop", "mask"}, nil
+		}
+		actions = append(actions, "unmask")
+	}
+
+	if s.Enabled != nil && *s.Enabled != current.enabled {
+		if *s.Enabled {
+			actions = append(actions, "enable")
+		} else {
+			actions = append(actions, "disable")
+		}
+	}
+
+	switch s.State {
+	case "":
+	case "started":
+		if !current.active {
+			actions = append(actions, "start")
+		}
+	case "stopped":
+		if current.active {
+			actions = append(actions, "stop")
+		}
+	case "restarted":
+		actions = append(actions, "restart")
+	case "reloaded":
+		if current.active {
+			actions = append(actions, "reload")
+		} else {
+			actions = append(actions, "start")
+		}
+	default:
+		return nil, fmt.Errorf("unknown state %s for service %s", s.State, s.Name)
+	}
+
+	return actions, nil
+}
+
+// EnsureService ensures that the service is in the desired state
+func (r *Remote) EnsureService(s types.Service) (types.StatusCode, error) {
+	systemd, err := r.hasSystemd()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	// unit files have been changed by Push, so systemd has to pick them up first
+	if systemd && r.unitsChanged {
+		res, err := r.run("sudo systemctl daemon-reload", bytes.NewBufferString(""))
+		if err != nil || !res.Success() {
+			return types.StatusFailed, errors.Errorf("daemon-reload failed: %v %s", err, res.Stderr.String())
+		}
+		r.unitsChanged = false
+	}
+
+	current, err := r.serviceState(s.Name)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	actions, err := serviceActions(s, current)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if len(actions) == 0 {
+		return types.StatusSatisfied, nil
+	}
+
+	for _, action := range actions {
+		err := r.checkConfig(s.Name, action)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		cmd, err := r.serviceCmd(s.Name, action)
+		if err != nil {
+			return types.StatusFailed, errors.Wrapf(err, "could not %s service %s", action, s.Name)
+		}
+
+		res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
+		if err != nil || !res.Success() {
+			return types.StatusFailed, errors.Errorf("could not %s service %s: %v %s", action, s.Name, err, res.Stderr.String())
+		}
+	}
+
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,66 @@
+package target
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestIsUnitFile(t *testing.T) {
+	cases := map[string]bool{
+		"/etc/systemd/system/app.service":       true,
+		"/lib/systemd/system/../system/a.timer": true,
+		"/etc/systemd/app.service":              false,
+		"/var/www/html/index.php":               false,
+	}
+
+	for path, expected := range cases {
+		if isUnitFile(path) != expected {
+			t.Errorf("%s: expected %v and got %v", path, expected, !expected)
+		}
+	}
+}
+
+func TestServiceActions(t *testing.T) {
+	yes, no := true, false
+
+	cases := []struct {
+		service  types.Service
+		current  serviceState
+		expected []string
+	}{
+		{types.Service{Name: "apache2", State: "started"}, serviceState{active: true}, []string{}},
+		{types.Service{Name: "apache2", State: "started", Enabled: &yes}, serviceState{}, []string{"enable", "start"}},
+		{types.Service{Name: "apache2", State: "stopped", Enabled: &no}, serviceState{active: true, enabled: true}, []string{"disable", "stop"}},
+		{types.Service{Name: "apache2", State: "restarted"}, serviceState{active: true}, []string{"restart"}},
+		{types.Service{Name: "apache2", State: "reloaded"}, serviceState{}, []string{"start"}},
+		{types.Service{Name: "apache2", State: "reloaded"}, serviceState{active: true}, []string{"reload"}},
+		{types.Service{Name: "apache2", State: "stopped", Masked: &yes}, serviceState{active: true}, []string{"stop", "mask"}},
+		{types.Service{Name: "apache2", Masked: &yes}, serviceState{masked: true}, []string{}},
+		{types.Service{Name: "apache2", State: "started", Masked: &no}, serviceState{masked: true}, []string{"unmask", "start"}},
+	}
+
+	for _, c := range cases {
+		actions, err := serviceActions(c.service, c.current)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if !reflect.DeepEqual(actions, c.expected) {
+			t.Errorf("expected %v and got %v", c.expected, actions)
+		}
+	}
+
+	for _, s := range []types.Service{
+		{Name: "apache2", State: "running"},
+		{Name: "apache2", State: "started", Masked: &yes},
+		{Name: "apache2", State: "reloaded", Masked: &yes},
+		{Name: "apache2", Enabled: &yes, Masked: &yes},
+	} {
+		_, err := serviceActions(s, serviceState{})
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", s)
+		}
+	}
+}
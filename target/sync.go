@@ -0,0 +1,313 @@
+package target
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+	"github.com/slack/target/types"
+)
+
+// defaultDirMode is the mode of the created directories when no mode is set
+const defaultDirMode = 0755
+
+// Sync mirrors the local directory of s to its remote path. Files are only
+// transferred when their size or modification time differ, like rsync's quick
+// check, and the modification time is copied so the next sync skips them.
+func (r *Remote) Sync(ctx context.Context, s types.Sync) (types.StatusCode, error) {
+	sftp, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	root := path.Clean(s.RemotePath)
+
+	uid, gid, err := r.ownerOf(s.Owner, s.Group)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	remote, err := remoteTree(sftp, root)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	fmt.Printf("trying to sync %s to %s on %s ...\n", s.LocalPath, root, r.addr)
+
+	// WalkDir does not descend into a symlinked root
+	localRoot, err := filepath.EvalSymlinks(s.LocalPath)
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "unable to resolve %s", s.LocalPath)
+	}
+
+	changed := 0
+	local := map[string]bool{}
+	err = filepath.WalkDir(localRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(localRoot, p)
+		if err != nil {
+			return err
+		}
+
+		dst := path.Join(root, filepath.ToSlash(rel))
+		local[dst] = true
+
+		// symlinks are synced as the file they point to. WalkDir does not
+		// descend into symlinked directories, they would be synced empty and
+		// their remote content deleted.
+		info, err := os.Stat(p)
+		if err != nil {
+			return errors.Wrapf(err, "unable to stat file %s", p)
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 && info.IsDir() {
+			return fmt.Errorf("%s is a symlink to a directory, which is not supported", p)
+		}
+
+		current := remote[dst]
+		if current != nil && current.IsDir() != info.IsDir() {
+			return fmt.Errorf("%s has a different type on %s", dst, r.addr)
+		}
+
+		var ok bool
+		if info.IsDir() {
+			ok, err = syncDir(sftp, dst, current, dirMode(s.DirMode, info), uid, gid)
+		} else {
+			ok, err = syncFile(sftp, p, dst, info, current, fileMode(s.Mode, info), uid, gid)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			changed++
+			if !info.IsDir() && isUnitFile(dst) {
+				r.unitsChanged = true
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "could not sync %s", s.LocalPath)
+	}
+
+	if s.Delete {
+		deleted, err := deleteExtraneous(sftp, remote, local)
+		changed += deleted
+		if err != nil {
+			return types.StatusFailed, err
+		}
+	}
+
+	if changed == 0 {
+		fmt.Printf("%s is already in sync on %s\n", root, r.addr)
+		return types.StatusSatisfied, nil
+	}
+
+	fmt.Printf("%s successfully synced on %s, %d changes\n", root, r.addr, changed)
+	return types.StatusEnforced, nil
+}
+
+// remoteTree lists the files and directories below root, it's empty when root
+// does not exist
+func remoteTree(client *sftp.Client, root string) (map[string]os.FileInfo, error) {
+	tree := map[string]os.FileInfo{}
+
+	walker := client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) && walker.Path() == root {
+				return tree, nil
+			}
+			return nil, errors.Wrapf(err, "unable to list %s", walker.Path())
+		}
+
+		tree[walker.Path()] = walker.Stat()
+	}
+
+	return tree, nil
+}
+
+// syncDir creates the remote directory and applies the mode and the owner
+func syncDir(client *sftp.Client, dst string, current os.FileInfo, mode os.FileMode, uid, gid int) (bool, error) {
+	if current == nil {
+		err := client.MkdirAll(dst)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to create directory %s", dst)
+		}
+	}
+
+	changed, err := syncAttrs(client, dst, current, mode, uid, gid)
+	return current == nil || changed, err
+}
+
+// syncFile transfers the file when it changed and applies the mode and the owner
+func syncFile(client *sftp.Client, src, dst string, info, current os.FileInfo, mode os.FileMode, uid, gid int) (bool, error) {
+	// sftp has a precision of seconds for the modification time
+	mtime := info.ModTime().Truncate(time.Second)
+	if current != nil && current.Size() == info.Size() && current.ModTime().Equal(mtime) {
+		return syncAttrs(client, dst, current, mode, uid, gid)
+	}
+
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to open file %s", src)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := client.Create(dst)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to create file %s", dst)
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to copy to file %s", dst)
+	}
+
+	err = client.Chtimes(dst, mtime, mtime)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to set modification time of %s", dst)
+	}
+
+	_, err = syncAttrs(client, dst, nil, mode, uid, gid)
+	return true, err
+}
+
+// syncAttrs applies mode and owner when they differ from the current ones, a
+// nil current always applies them. An uid or gid of -1 is left untouched.
+func syncAttrs(client *sftp.Client, dst string, current os.FileInfo, mode os.FileMode, uid, gid int) (bool, error) {
+	changed := false
+
+	if current == nil || current.Mode().Perm() != mode {
+		err := client.Chmod(dst, mode)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to chmod %s", dst)
+		}
+		changed = current != nil
+	}
+
+	if uid < 0 && gid < 0 {
+		return changed, nil
+	}
+
+	uid, gid, err := keepOwner(client, dst, current, uid, gid)
+	if err != nil {
+		return false, err
+	}
+
+	if current != nil {
+		if stat, ok := current.Sys().(*sftp.FileStat); ok && int(stat.UID) == uid && int(stat.GID) == gid {
+			return changed, nil
+		}
+	}
+
+	err = client.Chown(dst, uid, gid)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to chown %s", dst)
+	}
+
+	return changed || current != nil, nil
+}
+
+// keepOwner replaces an uid or gid of -1 with the current one of dst, as sftp
+// always sets both
+func keepOwner(client *sftp.Client, dst string, current os.FileInfo, uid, gid int) (int, int, error) {
+	if uid >= 0 && gid >= 0 {
+		return uid, gid, nil
+	}
+
+	if current == nil {
+		var err error
+		current, err = client.Stat(dst)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "unable to stat %s", dst)
+		}
+	}
+
+	stat, ok := current.Sys().(*sftp.FileStat)
+	if !ok {
+		return 0, 0, errors.Errorf("unable to read the owner of %s", dst)
+	}
+
+	if uid < 0 {
+		uid = int(stat.UID)
+	}
+
+	if gid < 0 {
+		gid = int(stat.GID)
+	}
+
+	return uid, gid, nil
+}
+
+// deleteExtraneous removes the remote entries which do not exist locally, the
+// deepest entries first so directories are empty when they are removed
+func deleteExtraneous(client *sftp.Client, remote map[string]os.FileInfo, local map[string]bool) (int, error) {
+	extraneous := []string{}
+	for p := range remote {
+		if !local[p] {
+			extraneous = append(extraneous, p)
+		}
+	}
+
+	sort.Slice(extraneous, func(i, j int) bool {
+		di, dj := strings.Count(extraneous[i], "/"), strings.Count(extraneous[j], "/")
+		if di != dj {
+			return di > dj
+		}
+		return extraneous[i] < extraneous[j]
+	})
+
+	for i, p := range extraneous {
+		var err error
+		if remote[p].IsDir() {
+			err = client.RemoveDirectory(p)
+		} else {
+			err = client.Remove(p)
+		}
+
+		if err != nil {
+			return i, errors.Wrapf(err, "unable to delete %s", p)
+		}
+	}
+
+	return len(extraneous), nil
+}
+
+// fileMode returns mode or the local mode of the file when mode is not set
+func fileMode(mode int, info os.FileInfo) os.FileMode {
+	if mode != 0 {
+		return os.FileMode(mode).Perm()
+	}
+
+	return info.Mode().Perm()
+}
+
+// dirMode returns mode or the local mode of the directory when mode is not set
+func dirMode(mode int, info os.FileInfo) os.FileMode {
+	if mode != 0 {
+		return os.FileMode(mode).Perm()
+	}
+
+	if info.Mode().Perm() == 0 {
+		return defaultDirMode
+	}
+
+	return info.Mode().Perm()
+}
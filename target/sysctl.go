@@ -0,0 +1,68 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// sysctlFile persists the parameters, so they are set again after a reboot
+var sysctlFile = "/etc/sysctl.d/99-goconf.conf"
+
+var sysctlName = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(/[a-zA-Z0-9_.-]+)*$`)
+
+// sysctlValue normalizes the whitespace of a value, sysctl prints the values of
+// parameters like net.ipv4.ip_local_port_range separated by tabs
+func sysctlValue(v string) string {
+	return strings.Join(strings.Fields(v), " ")
+}
+
+// EnsureSysctl sets a kernel parameter when its value differs and persists it
+func (r *Remote) EnsureSysctl(ctx context.Context, s types.Sysctl) (types.StatusCode, error) {
+	if !sysctlName.MatchString(s.Name) {
+		return types.StatusFailed, fmt.Errorf("invalid sysctl name %q", s.Name)
+	}
+
+	value := sysctlValue(s.Value)
+	if value == "" {
+		return types.StatusFailed, fmt.Errorf("sysctl %s has no value", s.Name)
+	}
+
+	// the current value is read first, so unknown parameters are not persisted
+	res, err := r.run("sysctl -n "+shellQuote(s.Name), bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not read sysctl %s: %v %s", s.Name, err, strings.TrimSpace(res.Stderr.String()))
+	}
+
+	// the value is set before it's persisted, so a value rejected by the kernel
+	// is not set again on the next boot
+	set := sysctlValue(res.Stdout.String()) != value
+	if set {
+		err = r.change("sudo sysctl -w " + shellQuote(s.Name+"="+value))
+		if err != nil {
+			return types.StatusFailed, err
+		}
+	}
+
+	line := types.Line{
+		Regexp: `^\s*` + regexp.QuoteMeta(s.Name) + `\s*=`,
+		Line:   fmt.Sprintf("%s = %s", s.Name, value),
+	}
+	persisted, err := r.editFile(sysctlFile, true, func(content string) (string, error) {
+		return editLine(content, line)
+	})
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !set && !persisted {
+		return types.StatusSatisfied, nil
+	}
+
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,104 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+var (
+	hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,62})(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,62}))*$`)
+	timezonePattern = regexp.MustCompile(`^[a-zA-Z0-9_+-]+(/[a-zA-Z0-9_+-]+)*$`)
+)
+
+// zoneinfoDir holds the timezone files, /etc/localtime links to one of them
+const zoneinfoDir = "/usr/share/zoneinfo/"
+
+// timezoneCmd prints the link of /etc/localtime, or /etc/timezone when it's no link
+const timezoneCmd = "readlink /etc/localtime 2>/dev/null || cat /etc/timezone"
+
+// parseTimezone returns the timezone of the output of timezoneCmd
+func parseTimezone(out string) string {
+	tz := strings.TrimSpace(out)
+	if i := strings.Index(tz, "zoneinfo/"); i >= 0 {
+		tz = tz[i+len("zoneinfo/"):]
+	}
+
+	return tz
+}
+
+// EnsureHostname sets the hostname when it differs
+func (r *Remote) EnsureHostname(ctx context.Context, name string) (types.StatusCode, error) {
+	if !hostnamePattern.MatchString(name) {
+		return types.StatusFailed, fmt.Errorf("invalid hostname %q", name)
+	}
+
+	res, err := r.run("hostname", bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not read hostname: %v %s", err, res.Stderr.String())
+	}
+
+	if strings.TrimSpace(res.Stdout.String()) == name {
+		return types.StatusSatisfied, nil
+	}
+
+	systemd, err := r.hasSystemd()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	cmd := "sudo hostnamectl set-hostname " + shellQuote(name)
+	if !systemd {
+		cmd = fmt.Sprintf("echo %s | sudo tee /etc/hostname >/dev/null && sudo hostname %s", shellQuote(name), shellQuote(name))
+	}
+
+	err = r.change(cmd)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	return types.StatusEnforced, nil
+}
+
+// EnsureTimezone sets the timezone like Europe/Berlin when it differs
+func (r *Remote) EnsureTimezone(ctx context.Context, tz string) (types.StatusCode, error) {
+	if !timezonePattern.MatchString(tz) {
+		return types.StatusFailed, fmt.Errorf("invalid timezone %q", tz)
+	}
+
+	res, err := r.run(timezoneCmd, bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("could not read timezone: %v %s", err, res.Stderr.String())
+	}
+
+	if parseTimezone(res.Stdout.String()) == tz {
+		return types.StatusSatisfied, nil
+	}
+
+	res, err = r.run("test -f "+shellQuote(zoneinfoDir+tz), bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return types.StatusFailed, errors.Errorf("unknown timezone %s, tzdata may be missing: %v", tz, err)
+	}
+
+	systemd, err := r.hasSystemd()
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	cmd := "sudo timedatectl set-timezone " + shellQuote(tz)
+	if !systemd {
+		cmd = fmt.Sprintf("sudo ln -sf %s /etc/localtime && echo %s | sudo tee /etc/timezone >/dev/null", shellQuote(zoneinfoDir+tz), shellQuote(tz))
+	}
+
+	err = r.change(cmd)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	return types.StatusEnforced, nil
+}
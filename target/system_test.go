@@ -0,0 +1,60 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestCronFile(t *testing.T) {
+	content, err := cronFile(types.Cron{Name: "backup", Schedule: "@daily", Job: "/usr/local/bin/backup"})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "# managed by goconf\n@daily root /usr/local/bin/backup\n"
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	// % is a newline for cron unless it's escaped
+	content, err = cronFile(types.Cron{Name: "backup", Schedule: "0 3 * * *", User: "backup", Job: `tar czf /backup/$(date +%F).tgz /var/www && date +\%s`})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected = "# managed by goconf\n0 3 * * * backup tar czf /backup/$(date +\\%F).tgz /var/www && date +\\%s\n"
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	for _, c := range []types.Cron{
+		{Name: "app.cron", Schedule: "@daily", Job: "true"},
+		{Name: "app", Schedule: "* * *", Job: "true"},
+		{Name: "app", Schedule: "@daily"},
+		{Name: "app", Schedule: "@daily", Job: "true\nfalse"},
+	} {
+		_, err = cronFile(c)
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", c)
+		}
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	cases := map[string]string{
+		"/usr/share/zoneinfo/Europe/Berlin\n": "Europe/Berlin",
+		"../usr/share/zoneinfo/Etc/UTC":       "Etc/UTC",
+		"America/New_York\n":                  "America/New_York",
+	}
+
+	for out, expected := range cases {
+		if tz := parseTimezone(out); tz != expected {
+			t.Errorf("expected %v and got %v", expected, tz)
+		}
+	}
+
+	if v := sysctlValue("32768\t60999\n"); v != "32768 60999" {
+		t.Errorf("expected %v and got %v", "32768 60999", v)
+	}
+}
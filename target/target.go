@@ -9,8 +9,8 @@ import (
 	"io"
 	"io/fs"
 	"os"
-	"strconv"
-	"strings"
+	"path"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/pkg/sftp"
@@ -36,19 +36,74 @@ type Remote struct {
 
 	// sftp holds all sftp connections. key is username
 	sftp map[string]*sftp.Client
+
+	// pkgmgr is the package manager of the target, detected on first use
+	pkgmgr PackageManager
+
+	// aptOptions are applied when the package manager is apt
+	aptOptions types.Apt
+
+	// systemd is set once the init system of the target is detected
+	systemd *bool
+
+	// unitsChanged is set when unit files are pushed and systemd has to reload them
+	unitsChanged bool
+
+	// ids caches the users and groups of the target
+	ids idCache
+
+	// phpVersion is the detected PHP version of the target
+	phpVersion string
+
+	// stdout and stderr receive the output of RunCmd while it's running
+	stdout io.Writer
+	stderr io.Writer
 }
 
 type Host interface {
 	RunCmd(cmd string, stdin io.Reader) (types.Response, error)
+	RunCmdStream(cmd string, stdin io.Reader, stdout, stderr io.Writer) (types.Response, error)
+	SetOutput(stdout, stderr io.Writer)
 	Push(ctx context.Context, files []types.File) error
+	EnsureFile(ctx context.Context, f types.File) (types.StatusCode, error)
+	EnsureLine(ctx context.Context, l types.Line) (types.StatusCode, error)
+	EnsureBlock(ctx context.Context, b types.Block) (types.StatusCode, error)
+	EnsurePHPIni(ctx context.Context, p types.PHPIni) (types.StatusCode, string, error)
+	EnsureApache(ctx context.Context, kind string, item types.ApacheItem) (types.StatusCode, error)
+	EnsureUser(ctx context.Context, u types.User) (types.StatusCode, error)
+	EnsureGroup(ctx context.Context, g types.UserGroup) (types.StatusCode, error)
+	EnsureAuthorizedKey(ctx context.Context, k types.AuthorizedKey) (types.StatusCode, error)
+	EnsureHostname(ctx context.Context, name string) (types.StatusCode, error)
+	EnsureTimezone(ctx context.Context, tz string) (types.StatusCode, error)
+	EnsureSysctl(ctx context.Context, s types.Sysctl) (types.StatusCode, error)
+	EnsureCron(ctx context.Context, c types.Cron) (types.StatusCode, error)
+	EnsureFirewall(ctx context.Context, fw types.Firewall) (types.StatusCode, error)
+	EnsureCertificate(ctx context.Context, c types.Certificate) (types.StatusCode, error)
+	Sync(ctx context.Context, s types.Sync) (types.StatusCode, error)
+	Fetch(ctx context.Context, remotePath, localPath string) (types.StatusCode, error)
 	Ensure(p types.APT) (types.StatusCode, error)
 	Remove(ctx context.Context, pkgs []types.Rule) error
-	Install(ctx context.Context, pkgs []types.Rule) error
+	Install(ctx context.Context, pkgs []types.Package) error
 	Run(ctx context.Context, pkgs []types.Rule) error
 	Restart(ctx context.Context, pkgs []types.Rule) error
+	EnsureService(s types.Service) (types.StatusCode, error)
+	SetPackageManager(name string) error
+	SetAptOptions(opts types.Apt) error
+	Facts(ctx context.Context) (*types.Facts, error)
+	Exec(ctx context.Context, c types.Command) (types.StatusCode, types.Response, error)
+	RunScript(ctx context.Context, s types.Script) (types.StatusCode, types.Response, error)
+	Repositories(ctx context.Context, repos []types.Repository) (bool, error)
+	UpdateCache(ctx context.Context, force bool, maxAge int) (types.StatusCode, error)
+	RebootRequired(ctx context.Context) (bool, error)
+	BootID(ctx context.Context) (string, error)
+	Reboot(ctx context.Context) error
 	Close() error
 }
 
+// dialTimeout limits the time to establish the SSH connection, so hosts which
+// are down or rebooting fail fast
+const dialTimeout = 30 * time.Second
+
 // New returns a new Remote target from connection details
 func New(addr string, user string, sudopass string, hostkeycallback ssh.HostKeyCallback, auths ...ssh.AuthMethod) (Host, error) {
 
@@ -64,6 +119,7 @@ func New(addr string, user string, sudopass string, hostkeycallback ssh.HostKeyC
 		User:            user,
 		Auth:            auths,
 		HostKeyCallback: hostkeycallback,
+		Timeout:         dialTimeout,
 	}
 
 	var err error
@@ -105,9 +161,22 @@ func (r *Remote) sftpClient() (*sftp.Client, error) {
 }
 
 // Run executes cmd on Remote with the currently active user and returns the response.
-// Reader stdin is used to add stdin.
+// Reader stdin is used to add stdin. The output is streamed to the writers set by SetOutput.
 func (r *Remote) RunCmd(cmd string, stdin io.Reader) (types.Response, error) {
-	return r.run(cmd, stdin)
+	return r.runStream(cmd, stdin, r.stdout, r.stderr)
+}
+
+// RunCmdStream executes cmd like RunCmd and writes stdout and stderr to the
+// writers as they arrive. The writers are optional.
+func (r *Remote) RunCmdStream(cmd string, stdin io.Reader, stdout, stderr io.Writer) (types.Response, error) {
+	return r.runStream(cmd, stdin, stdout, stderr)
+}
+
+// SetOutput sets the writers receiving the output of RunCmd while it's running,
+// nil writers disable the streaming
+func (r *Remote) SetOutput(stdout, stderr io.Writer) {
+	r.stdout = stdout
+	r.stderr = stderr
 }
 
 // Push files concurrently using sftp to the target server
@@ -123,10 +192,24 @@ func (r *Remote) Push(ctx context.Context, files []types.File) error {
 		return errors.Wrap(err, "sftp client is not ready or not found")
 	}
 
-	defer sftp.Close()
+	// the sftp client is kept open for other rules and closed by Close
 
-	for _, cfile := range files {
-		file := cfile
+	// owners are resolved before the files are pushed concurrently
+	owners := make([][2]int, len(files))
+	for i, file := range files {
+		if isUnitFile(file.RemotePath) {
+			r.unitsChanged = true
+		}
+
+		uid, gid, err := r.fileOwner(file)
+		if err != nil {
+			return errors.Wrapf(err, "could not resolve owner of %s", file.RemotePath)
+		}
+		owners[i] = [2]int{uid, gid}
+	}
+
+	for i, cfile := range files {
+		file, owner := cfile, owners[i]
 		errs.Go(func() error {
 			srcFile, err := os.Open(file.LocalPath)
 			if err != nil {
@@ -161,34 +244,16 @@ func (r *Remote) Push(ctx context.Context, files []types.File) error {
 				return errors.Wrap(err, "chmod error")
 			}
 
-			cmd := fmt.Sprintf("id -u %s", file.Owner)
-			res, err := r.run(cmd, bytes.NewBufferString(""))
-
-			if err != nil || !res.Success() {
-				return errors.Wrap(errors.New(res.Stderr.String()), "owner error")
-			}
-
-			str := strings.ReplaceAll(res.Stdout.String(), "\n", "")
-			uid, err := strconv.Atoi(strings.TrimSpace(str))
-			if err != nil {
-				return errors.Wrap(err, "uid error")
-			}
-
-			cmd = fmt.Sprintf("id -g %s", file.Group)
-			res, err = r.run(cmd, bytes.NewBufferString(""))
-			if err != nil || !res.Success() {
-				return errors.Wrap(errors.New(res.Stderr.String()), "group error")
-			}
+			if owner[0] >= 0 || owner[1] >= 0 {
+				uid, gid, err := keepOwner(sftp, file.RemotePath, nil, owner[0], owner[1])
+				if err != nil {
+					return err
+				}
 
-			str = strings.ReplaceAll(res.Stdout.String(), "\n", "")
-			gid, err := strconv.Atoi(strings.TrimSpace(str))
-			if err != nil {
-				return errors.Wrap(err, "gid error")
-			}
-
-			err = sftp.Chown(file.RemotePath, uid, gid)
-			if err != nil {
-				return errors.Wrap(err, "chown error")
+				err = sftp.Chown(file.RemotePath, uid, gid)
+				if err != nil {
+					return errors.Wrap(err, "chown error")
+				}
 			}
 
 			fmt.Printf("%s successfully pushed on %s\n", file.RemotePath, r.addr)
@@ -202,66 +267,126 @@ func (r *Remote) Push(ctx context.Context, files []types.File) error {
 
 // Check checks if package is in the desired state
 func (r *Remote) check(p types.APT) (bool, error) {
-	cmd := fmt.Sprintf(`dpkg-query -f '${Package}\t${db:Status-Abbrev}\t${Version}\t${Name}' -W %s`, p.Name)
+	pm, err := r.packageManager()
+	if err != nil {
+		return false, err
+	}
 
-	res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
+	res, err := r.run(pm.Query(p.Name), bytes.NewBufferString(""))
 	if err != nil {
 		return false, errors.Wrapf(err, "could not check package status for %s", p.Name)
 	}
 
-	if res.ExitStatus == 0 && p.Status == types.StatusNotInstalled {
+	status, version := pm.Parse(res)
+	if status != p.Status {
 		return false, nil
 	}
 
-	if res.ExitStatus != 0 && p.Status == types.StatusInstalled {
-		return false, nil
+	if p.Status == types.StatusInstalled && p.Version != "" {
+		return matchVersion(p.Version, version), nil
+	}
+
+	return true, nil
+}
+
+// matchVersion checks if version satisfies the constraint, which is the exact
+// version or a pattern like 8.1.*
+func matchVersion(constraint, version string) bool {
+	ok, err := path.Match(constraint, version)
+	if err != nil {
+		return constraint == version
 	}
 
-	var status byte
-	// the package info has been returned, so we get the status byte
-	stdOutarr := strings.Split(res.Stdout.String(), "\t")
-	if len(stdOutarr) > 1 {
-		status = stdOutarr[1][1]
+	return ok
+}
+
+// hold holds or releases pkg, it returns true if the hold state changed
+func (r *Remote) hold(pm PackageManager, pkg string, hold bool) (bool, error) {
+	res, err := r.run(pm.Held(pkg), bytes.NewBufferString(""))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not check hold of %s", pkg)
 	}
 
-	if status != byte(p.Status) {
+	if res.Success() == hold {
 		return false, nil
 	}
 
+	res, err = r.RunCmd(pm.Hold(pkg, hold), bytes.NewBufferString(""))
+	if err != nil || !res.Success() {
+		return false, errors.Errorf("could not change hold of %s: %v %s", pkg, err, res.Stderr.String())
+	}
+
 	return true, nil
 }
 
-// Ensure ensures that the package is in the desired state
-func (r *Remote) Ensure(p types.APT) (types.StatusCode, error) {
-
+// ensurePackage ensures that the package is installed or removed with the
+// desired version and hold
+func (r *Remote) ensurePackage(p types.APT) (types.StatusCode, error) {
 	ok, err := r.check(p)
 	if err != nil {
 		return types.StatusFailed, errors.Wrap(err, "ensure check failed")
 	}
 
-	if ok && !p.Service() {
-		return types.StatusSatisfied, nil
+	pm, err := r.packageManager()
+	if err != nil {
+		return types.StatusFailed, err
 	}
 
-	actions := map[types.Status]string{
-		types.StatusInstalled:    "install",
-		types.StatusNotInstalled: "purge",
+	status := types.StatusSatisfied
+	if !ok {
+		var cmd, action string
+		switch p.Status {
+		case types.StatusInstalled:
+			cmd, action = pm.Install(p.Name, p.Version), "install"
+		case types.StatusNotInstalled:
+			cmd, action = pm.Remove(p.Name), "remove"
+		default:
+			return types.StatusFailed, fmt.Errorf("unknown package status %v for %s", p.Status, p.Name)
+		}
+
+		res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
+		if err != nil || !res.Success() {
+			return types.StatusFailed, errors.Wrapf(err, "could not %s package %s", action, p.Name)
+		}
+		status = types.StatusEnforced
+	}
+
+	if p.Hold != nil && p.Status == types.StatusInstalled {
+		changed, err := r.hold(pm, p.Name, *p.Hold)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		if changed {
+			status = types.StatusEnforced
+		}
+	}
+
+	return status, nil
+}
 
+// Ensure ensures that the package is in the desired state
+func (r *Remote) Ensure(p types.APT) (types.StatusCode, error) {
+	if !p.Service() {
+		return r.ensurePackage(p)
+	}
+
+	actions := map[types.Status]string{
 		types.StatusStarted:   "start",
 		types.StatusRestarted: "restart",
+		types.StatusStopped:   "stop",
+		types.StatusReloaded:  "reload",
 	}
 
-	cmd := fmt.Sprintf("apt %s %s -y", actions[p.Status], p.Name)
-
-	// on purge remove dependencies
-	if p.Status == types.StatusNotInstalled {
-		cmd = fmt.Sprintf("apt %s %s -y && apt autoremove -y", actions[p.Status], p.Name)
+	err := r.checkConfig(p.Name, actions[p.Status])
+	if err != nil {
+		return types.StatusFailed, err
 	}
 
-	if p.Service() {
-		cmd = fmt.Sprintf("sudo service %s %s", p.Name, actions[p.Status])
+	cmd, err := r.serviceCmd(p.Name, actions[p.Status])
+	if err != nil {
+		return types.StatusFailed, err
 	}
-	// TODO for apache check for firewall ->  sudo ufw allow 'Apache'
 
 	res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
 	if err != nil || !res.Success() {
@@ -271,48 +396,93 @@ func (r *Remote) Ensure(p types.APT) (types.StatusCode, error) {
 	return types.StatusEnforced, nil
 }
 
+// preseed sets the debconf values so apt does not prompt for them
+func (r *Remote) preseed() error {
+	if len(r.aptOptions.Preseed) == 0 {
+		return nil
+	}
+
+	pm, err := r.packageManager()
+	if err != nil {
+		return err
+	}
+
+	a, ok := pm.(apt)
+	if !ok {
+		return nil
+	}
+
+	cmd, stdin := a.Preseed()
+	res, err := r.RunCmd(cmd, bytes.NewBufferString(stdin))
+	if err != nil || !res.Success() {
+		return errors.Errorf("could not preseed debconf values: %v %s", err, res.Stderr.String())
+	}
+
+	return nil
+}
+
 // Remove removes package and make sure it's in the desired state
 func (r *Remote) Remove(ctx context.Context, pkgs []types.Rule) error {
 	for _, pkg := range pkgs {
 		p := types.APT{
-			Name:   string(pkg),
+			Name:   pkg.Name,
 			Status: types.StatusNotInstalled,
 			User:   r.activeUser,
 		}
 
-		fmt.Printf("trying to remove %s on %s ...\n", pkg, r.addr)
+		fmt.Printf("trying to remove %s on %s ...\n", pkg.Name, r.addr)
 
 		status, err := r.Ensure(p)
 		if err != nil || !status.Success() {
-			fmt.Printf("could not remove %s on %s with err=%v\n", pkg, r.addr, err)
+			fmt.Printf("could not remove %s on %s with err=%v\n", pkg.Name, r.addr, err)
 			continue
 		}
 
-		fmt.Printf("%s is removed on %s\n", pkg, r.addr)
+		fmt.Printf("%s is removed on %s\n", pkg.Name, r.addr)
 	}
 
 	return nil
 }
 
 // Install installs package and make sure it's in the desired state
-func (r *Remote) Install(ctx context.Context, pkgs []types.Rule) error {
+func (r *Remote) Install(ctx context.Context, pkgs []types.Package) error {
+	// packages may add users and groups, e.g. www-data or redis
+	defer r.resetIDs()
+
+	err := r.preseed()
+	if err != nil {
+		return err
+	}
 
 	for _, pkg := range pkgs {
 		p := types.APT{
-			Name:   string(pkg),
-			Status: types.StatusInstalled,
-			User:   r.activeUser,
+			Name:    pkg.Name,
+			Status:  types.StatusInstalled,
+			User:    r.activeUser,
+			Version: pkg.Version,
+			Hold:    pkg.Hold,
 		}
 
-		fmt.Printf("trying to install %s on %s ...\n", pkg, r.addr)
+		name := pkg.Name
+		if pkg.Deb != "" {
+			name = pkg.Deb
+		}
+
+		fmt.Printf("trying to install %s on %s ...\n", name, r.addr)
+
+		var status types.StatusCode
+		if pkg.Deb != "" {
+			status, err = r.ensureDeb(ctx, pkg)
+		} else {
+			status, err = r.Ensure(p)
+		}
 
-		status, err := r.Ensure(p)
 		if err != nil || !status.Success() {
-			fmt.Printf("could not install %s on %s with err=%v\n", pkg, r.addr, err)
+			fmt.Printf("could not install %s on %s with err=%v\n", name, r.addr, err)
 			continue
 		}
 
-		fmt.Printf("%s is installed on %s\n", pkg, r.addr)
+		fmt.Printf("%s is installed on %s\n", name, r.addr)
 	}
 
 	return nil
@@ -322,20 +492,20 @@ func (r *Remote) Install(ctx context.Context, pkgs []types.Rule) error {
 func (r *Remote) Run(ctx context.Context, pkgs []types.Rule) error {
 	for _, service := range pkgs {
 		p := types.APT{
-			Name:   string(service),
+			Name:   service.Name,
 			Status: types.StatusStarted,
 			User:   r.activeUser,
 		}
 
-		fmt.Printf("trying to run %s on %s ...\n", service, r.addr)
+		fmt.Printf("trying to run %s on %s ...\n", service.Name, r.addr)
 
 		status, err := r.Ensure(p)
 		if err != nil || !status.Success() {
-			fmt.Printf("could not run %s on %s with err=%v\n", service, r.addr, err)
+			fmt.Printf("could not run %s on %s with err=%v\n", service.Name, r.addr, err)
 			continue
 		}
 
-		fmt.Printf("%s ran on %s\n", service, r.addr)
+		fmt.Printf("%s ran on %s\n", service.Name, r.addr)
 	}
 
 	return nil
@@ -345,20 +515,20 @@ func (r *Remote) Run(ctx context.Context, pkgs []types.Rule) error {
 func (r *Remote) Restart(ctx context.Context, pkgs []types.Rule) error {
 	for _, service := range pkgs {
 		p := types.APT{
-			Name:   string(service),
+			Name:   service.Name,
 			Status: types.StatusRestarted,
 			User:   r.activeUser,
 		}
 
-		fmt.Printf("trying to restart %s on %s ...\n", service, r.addr)
+		fmt.Printf("trying to restart %s on %s ...\n", service.Name, r.addr)
 
 		status, err := r.Ensure(p)
 		if err != nil || !status.Success() {
-			fmt.Printf("could not restart %s on %s with err=%v\n", service, r.addr, err)
+			fmt.Printf("could not restart %s on %s with err=%v\n", service.Name, r.addr, err)
 			continue
 		}
 
-		fmt.Printf("%s restarted on %s\n", service, r.addr)
+		fmt.Printf("%s restarted on %s\n", service.Name, r.addr)
 
 	}
 	return nil
@@ -366,6 +536,12 @@ func (r *Remote) Restart(ctx context.Context, pkgs []types.Rule) error {
 
 // run runs cmd on remote
 func (r *Remote) run(cmd string, stdin io.Reader) (types.Response, error) {
+	return r.runStream(cmd, stdin, nil, nil)
+}
+
+// runStream runs cmd on remote and copies the output to stdout and stderr
+// while it's buffered in the response
+func (r *Remote) runStream(cmd string, stdin io.Reader, stdout, stderr io.Writer) (types.Response, error) {
 	session, err := r.conn.NewSession()
 	resp := types.Response{}
 
@@ -375,7 +551,15 @@ func (r *Remote) run(cmd string, stdin io.Reader) (types.Response, error) {
 	defer session.Close()
 
 	session.Stdout = &resp.Stdout
+	if stdout != nil {
+		session.Stdout = io.MultiWriter(&resp.Stdout, stdout)
+	}
+
 	session.Stderr = &resp.Stderr
+	if stderr != nil {
+		session.Stderr = io.MultiWriter(&resp.Stderr, stderr)
+	}
+
 	session.Stdin = stdin
 
 	// TODO: convert it session.StdinPipe() for conccurent  commands
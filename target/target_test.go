@@ -4,7 +4,12 @@ import (
 	"bytes"
 	"context"
 	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/slack/internal"
 	"github.com/slack/target/types"
@@ -24,6 +29,8 @@ func TestRemote(t *testing.T) {
 
 	done := make(chan bool, 1)
 	go internal.SetupTestSSH(done)
+	// wait for the test server to listen
+	time.Sleep(200 * time.Millisecond)
 
 	// happy path
 	r, err := New(internal.LocalAddrString, "staff", "", ssh.InsecureIgnoreHostKey(), ssh.Password(""))
@@ -42,11 +49,80 @@ func TestRemote(t *testing.T) {
 		t.Errorf("expected error and got nil")
 	}
 
-	r, err = New(internal.LocalAddrString, "staff", "", ssh.InsecureIgnoreHostKey(), ssh.Password(""))
+	// the test server knows the current user only
+	current, err := user.Current()
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	r, err = New(internal.LocalAddrString, current.Username, "", ssh.InsecureIgnoreHostKey(), ssh.Password(""))
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	// stream the output while keeping the response
+	out := bytes.Buffer{}
+	res, err := r.RunCmdStream("echo", bytes.NewBufferString(""), &out, nil)
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
 	}
 
+	if out.String() != res.Stdout.String() || out.String() != "test" {
+		t.Errorf("expected %v and got %v", res.Stdout.String(), out.String())
+	}
+
+	// resolve owners with the cached passwd and group databases
+	remote := r.(*Remote)
+	uid, _ := strconv.Atoi(current.Uid)
+	gid, _ := strconv.Atoi(current.Gid)
+	ownerUID, ownerGID, err := remote.ownerIDs(current.Username, "")
+	if err != nil || ownerUID != uid || ownerGID != gid {
+		t.Errorf("expected %v and got %v %v %v", []int{uid, gid}, ownerUID, ownerGID, err)
+	}
+
+	if remote.ids.users == nil || remote.ids.groups == nil {
+		t.Errorf("expected the users and groups to be cached")
+	}
+
+	ownerUID, ownerGID, err = remote.fileOwner(types.File{Owner: "nobody-else", UID: &uid, GID: &gid})
+	if err != nil || ownerUID != uid || ownerGID != gid {
+		t.Errorf("expected %v and got %v %v %v", []int{uid, gid}, ownerUID, ownerGID, err)
+	}
+
+	for _, owner := range [][2]string{{"nobody-else", ""}, {current.Username, "nogroup-else"}} {
+		_, _, err = remote.ownerIDs(owner[0], owner[1])
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", owner)
+		}
+	}
+
+	// users which are not listed by getent, e.g. of LDAP, are looked up by name
+	remote.ids.users, remote.ids.groups = map[string]passwdEntry{}, map[string]groupEntry{}
+	ownerUID, ownerGID, err = remote.ownerIDs(current.Username, current.Gid)
+	if err != nil || ownerUID != uid || ownerGID != gid {
+		t.Errorf("expected %v and got %v %v %v", []int{uid, gid}, ownerUID, ownerGID, err)
+	}
+
+	if _, ok := remote.ids.users[current.Username]; !ok {
+		t.Errorf("expected %s to be cached", current.Username)
+	}
+
+	if u, err := remote.lookupUID(uid); err != nil || u == nil {
+		t.Errorf("expected %v and got %v %v", current.Username, u, err)
+	}
+
+	if g, err := user.LookupGroupId(current.Gid); err == nil {
+		found, err := remote.lookupGroup(g.Name)
+		if err != nil || found == nil || found.gid != gid {
+			t.Errorf("expected %v and got %v %v", gid, found, err)
+		}
+	}
+
+	remote.resetIDs()
+	if remote.ids.users != nil {
+		t.Errorf("expected the cache to be reset")
+	}
+
 	// push files
 	remotePath := "testdata/tindex.php"
 	localPath := "testdata/index.php"
@@ -54,8 +130,8 @@ func TestRemote(t *testing.T) {
 		{
 			RemotePath: remotePath,
 			LocalPath:  localPath,
-			Owner:      "whoami",
-			Group:      "whoami",
+			Owner:      current.Username,
+			Group:      current.Gid,
 			Mode:       0644,
 		},
 	})
@@ -78,9 +154,38 @@ func TestRemote(t *testing.T) {
 		t.Errorf("local files is different than remote file")
 	}
 
+	// add repositories
+	sourcesDir, keyringsDir = t.TempDir(), t.TempDir()
+	changed, err := r.Repositories(context.Background(), []types.Repository{
+		{
+			Name:       "php",
+			URIs:       []string{"https://packages.sury.org/php/"},
+			Suites:     []string{"bullseye"},
+			Components: []string{"main"},
+			Key:        "testdata/php.asc",
+		},
+	})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if !changed {
+		t.Errorf("expected %v and got %v", true, changed)
+	}
+
+	if _, err := os.Stat(keyringsDir + "/php.asc"); err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	_, err = r.UpdateCache(context.Background(), changed, 0)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
 	// ensure rule
 	p := types.APT{
-		Name: "apache2",
+		Name:   "apache2",
+		Status: types.StatusInstalled,
 	}
 	_, err = r.Ensure(p)
 	if err != nil {
@@ -89,7 +194,9 @@ func TestRemote(t *testing.T) {
 
 	// test Runs
 	err = r.Run(context.Background(), []types.Rule{
-		"apache2",
+		{
+			Name: "apache2",
+		},
 	})
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
@@ -97,15 +204,467 @@ func TestRemote(t *testing.T) {
 
 	// test Restarts
 	err = r.Restart(context.Background(), []types.Rule{
-		"apache2",
+		{
+			Name: "apache2",
+		},
+	})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	// test Services
+	status, err := r.EnsureService(types.Service{Name: "apache2", State: "reloaded"})
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	// test Exec
+	status, _, err = r.Exec(context.Background(), types.Command{
+		Command: "php artisan migrate --force",
+		Dir:     "/var/www/app",
+	})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	status, _, err = r.Exec(context.Background(), types.Command{
+		Command: "composer install",
+		Guards: types.Guards{
+			Creates: "/var/www/app/vendor",
+		},
 	})
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
 	}
 
+	if status != types.StatusSatisfied {
+		t.Errorf("expected %v and got %v", types.StatusSatisfied, status)
+	}
+
+	_, _, err = r.Exec(context.Background(), types.Command{})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	// test scripts
+	status, _, err = r.RunScript(context.Background(), types.Script{
+		Path: "testdata/hello.sh",
+		Args: []string{"world"},
+	})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	_, _, err = r.RunScript(context.Background(), types.Script{
+		Path: "testdata/missing.sh",
+	})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	// test directory sync
+	syncDir := t.TempDir()
+	err = os.WriteFile(syncDir+"/old.php", []byte("<?php"), 0644)
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	sync := types.Sync{
+		LocalPath:  "testdata",
+		RemotePath: syncDir + "/app",
+		DirMode:    0750,
+		Delete:     true,
+	}
+
+	status, err = r.Sync(context.Background(), sync)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	if _, err := os.Stat(syncDir + "/app/hello.sh"); err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	status, err = r.Sync(context.Background(), sync)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusSatisfied {
+		t.Errorf("expected %v and got %v", types.StatusSatisfied, status)
+	}
+
+	// extraneous files are deleted
+	sync.RemotePath = syncDir
+	_, err = r.Sync(context.Background(), sync)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if _, err := os.Stat(syncDir + "/old.php"); !os.IsNotExist(err) {
+		t.Errorf("expected %v and got %v", "not exist", err)
+	}
+
+	// symlinked directories are refused, the remote content is kept
+	linkDir := t.TempDir()
+	testdata, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	err = os.Symlink(testdata, linkDir+"/app")
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	_, err = r.Sync(context.Background(), types.Sync{LocalPath: linkDir, RemotePath: syncDir, Delete: true})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	if _, err := os.Stat(syncDir + "/hello.sh"); err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	// a symlinked root is synced as the directory it points to
+	_, err = r.Sync(context.Background(), types.Sync{LocalPath: linkDir + "/app", RemotePath: syncDir + "/linked"})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if _, err := os.Stat(syncDir + "/linked/hello.sh"); err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	// test transfer_files states
+	filesDir := t.TempDir()
+	files := []types.File{
+		{RemotePath: filesDir + "/conf.d", State: types.FileStateDirectory},
+		{RemotePath: filesDir + "/conf.d/php.ini", Content: "memory_limit = 256M\n", Mode: 0640},
+		{RemotePath: filesDir + "/php.ini", State: types.FileStateLink, Target: filesDir + "/conf.d/php.ini"},
+	}
+
+	for _, f := range files {
+		status, err = r.EnsureFile(context.Background(), f)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if status != types.StatusEnforced {
+			t.Errorf("%s: expected %v and got %v", f.RemotePath, types.StatusEnforced, status)
+		}
+
+		status, err = r.EnsureFile(context.Background(), f)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if status != types.StatusSatisfied {
+			t.Errorf("%s: expected %v and got %v", f.RemotePath, types.StatusSatisfied, status)
+		}
+	}
+
+	content, err := os.ReadFile(filesDir + "/php.ini")
+	if err != nil || string(content) != "memory_limit = 256M\n" {
+		t.Errorf("expected %v and got %v", "memory_limit = 256M", string(content))
+	}
+
+	// only the group is changed when no owner is set, root can give the file away
+	group := current.Gid
+	if current.Uid == "0" {
+		group = "65534"
+	}
+
+	status, err = r.EnsureFile(context.Background(), types.File{RemotePath: filesDir + "/group.ini", Content: "memory_limit = 256M\n", Group: group})
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	if st, err := os.Stat(filesDir + "/group.ini"); err == nil {
+		stat := st.Sys().(*syscall.Stat_t)
+		if strconv.Itoa(int(stat.Gid)) != group || strconv.Itoa(int(stat.Uid)) != current.Uid {
+			t.Errorf("expected %v and got %v %v", []string{current.Uid, group}, stat.Uid, stat.Gid)
+		}
+	} else {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	status, err = r.EnsureFile(context.Background(), types.File{RemotePath: filesDir + "/conf.d", State: types.FileStateAbsent})
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if _, err := os.Stat(filesDir + "/conf.d"); status != types.StatusEnforced || !os.IsNotExist(err) {
+		t.Errorf("expected %v and got %v", "conf.d to be removed", err)
+	}
+
+	_, err = r.EnsureFile(context.Background(), types.File{RemotePath: filesDir + "/php.ini", State: "missing"})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	for _, f := range []types.File{
+		{RemotePath: filesDir + "/php.ini"},
+		{RemotePath: filesDir + "/php.ini", LocalPath: "testdata/php.ini", Content: "memory_limit = 1G\n"},
+	} {
+		_, err = r.EnsureFile(context.Background(), f)
+		if err == nil {
+			t.Errorf("%v: expected error and got nil", f)
+		}
+	}
+
+	// test lines and blocks
+	iniPath := filesDir + "/edit.ini"
+	err = os.WriteFile(iniPath, []byte("memory_limit = 128M\n"), 0640)
+	if err != nil {
+		t.Fatalf("expected no errors and got err=%v", err.Error())
+	}
+
+	line := types.Line{Path: iniPath, Regexp: "^memory_limit", Line: "memory_limit = 256M"}
+	for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+		status, err = r.EnsureLine(context.Background(), line)
+		if err != nil {
+			t.Errorf("expected no errors and got err=%v", err.Error())
+		}
+
+		if status != expected {
+			t.Errorf("expected %v and got %v", expected, status)
+		}
+	}
+
+	status, err = r.EnsureBlock(context.Background(), types.Block{Path: iniPath, Block: "opcache.enable=1", Marker: "; {mark} opcache"})
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	content, err = os.ReadFile(iniPath)
+	if err != nil || string(content) != "memory_limit = 256M\n; BEGIN opcache\nopcache.enable=1\n; END opcache\n" {
+		t.Errorf("expected %v and got %v", "edited php.ini", string(content))
+	}
+
+	if st, err := os.Stat(iniPath); err != nil || st.Mode().Perm() != 0640 {
+		t.Errorf("expected %v and got %v", "mode 0640 to be kept", st)
+	}
+
+	_, err = r.EnsureLine(context.Background(), types.Line{Path: filesDir + "/missing.ini", Line: "a = b"})
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	// test php.ini
+	phpIni := types.PHPIni{
+		Path:     iniPath,
+		Settings: map[string]string{"memory_limit": "512M"},
+	}
+
+	status, service, err := r.EnsurePHPIni(context.Background(), phpIni)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusEnforced || service != "apache2" {
+		t.Errorf("expected %v and got %v", "apache2 to be notified", service)
+	}
+
+	status, service, err = r.EnsurePHPIni(context.Background(), phpIni)
+	if err != nil || status != types.StatusSatisfied || service != "" {
+		t.Errorf("expected %v and got %v", types.StatusSatisfied, status)
+	}
+
+	phpIni.SAPI = "cgi"
+	_, _, err = r.EnsurePHPIni(context.Background(), phpIni)
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+
+	// test apache sites, the test server reports everything as enabled
+	status, err = r.EnsureApache(context.Background(), ApacheSite, types.ApacheItem{Name: "000-default"})
+	if err != nil || status != types.StatusSatisfied {
+		t.Errorf("expected %v and got %v", types.StatusSatisfied, status)
+	}
+
+	status, err = r.EnsureApache(context.Background(), ApacheSite, types.ApacheItem{Name: "000-default", State: types.StateDisabled})
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	// test cron jobs and sysctl in a temp dir instead of /etc
+	cronDir = t.TempDir()
+	sysctlFile = cronDir + "/99-goconf.conf"
+	cron := types.Cron{Name: "app-queue", Schedule: "*/5 * * * *", User: "www-data", Job: "php /var/www/html/artisan queue:work"}
+	for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+		status, err = r.EnsureCron(context.Background(), cron)
+		if err != nil || status != expected {
+			t.Errorf("expected %v and got %v %v", expected, status, err)
+		}
+	}
+
+	cron.State = types.StateAbsent
+	for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+		status, err = r.EnsureCron(context.Background(), cron)
+		if err != nil || status != expected {
+			t.Errorf("expected %v and got %v %v", expected, status, err)
+		}
+	}
+
+	// the test server prints test for every sysctl
+	for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+		status, err = r.EnsureSysctl(context.Background(), types.Sysctl{Name: "vm.swappiness", Value: "test"})
+		if err != nil || status != expected {
+			t.Errorf("expected %v and got %v %v", expected, status, err)
+		}
+	}
+
+	status, err = r.EnsureSysctl(context.Background(), types.Sysctl{Name: "vm.swappiness", Value: "10"})
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	content, err = os.ReadFile(sysctlFile)
+	if err != nil || string(content) != "vm.swappiness = 10\n" {
+		t.Errorf("expected %q and got %q", "vm.swappiness = 10\n", string(content))
+	}
+
+	// test the firewall, the test server is connected on port 2222
+	ufwDefaults = cronDir + "/ufw"
+	err = os.WriteFile(ufwDefaults, []byte("DEFAULT_INPUT_POLICY=\"DROP\"\n"), 0644)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	status, err = r.EnsureFirewall(context.Background(), types.Firewall{Rules: []types.FirewallRule{{App: "Apache Full"}}})
+	if err == nil || status != types.StatusFailed {
+		t.Errorf("expected %v and got %v", types.StatusFailed, status)
+	}
+
+	fw := types.Firewall{Incoming: "deny", Rules: []types.FirewallRule{{Port: "2222", Proto: "tcp"}, {Port: "80", Proto: "tcp"}}}
+	status, err = r.EnsureFirewall(context.Background(), fw)
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	nftFile, nftConf = cronDir+"/nftables.d/goconf.nft", cronDir+"/nftables.conf"
+	fw.Backend = FirewallNftables
+	for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+		status, err = r.EnsureFirewall(context.Background(), fw)
+		if err != nil || status != expected {
+			t.Errorf("expected %v and got %v %v", expected, status, err)
+		}
+	}
+
+	// test certificates, they are owned by root
+	certFile, keyFile, chainFile, err := internal.WriteTestCertificate(t.TempDir(), time.Now().Add(24*time.Hour))
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	cert := types.Certificate{
+		Name:      "site",
+		Cert:      certFile,
+		Key:       keyFile,
+		Chain:     chainFile,
+		CertPath:  cronDir + "/ssl/site.crt",
+		KeyPath:   cronDir + "/ssl/private/site.key",
+		ChainPath: cronDir + "/ssl/site.chain.crt",
+	}
+
+	status, err = r.EnsureCertificate(context.Background(), types.Certificate{Name: "site", Cert: certFile, Key: chainFile})
+	if err == nil || status != types.StatusFailed {
+		t.Errorf("expected %v and got %v", types.StatusFailed, status)
+	}
+
+	// expired certificates are not pushed
+	expiredCert, expiredKey, _, err := internal.WriteTestCertificate(t.TempDir(), time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expired := types.Certificate{Name: "expired", Cert: expiredCert, Key: expiredKey, CertPath: cronDir + "/ssl/expired.crt", KeyPath: cronDir + "/ssl/private/expired.key"}
+	status, err = r.EnsureCertificate(context.Background(), expired)
+	if err == nil || status != types.StatusFailed {
+		t.Errorf("expected %v and got %v", types.StatusFailed, status)
+	}
+
+	if _, err := os.Stat(expired.KeyPath); !os.IsNotExist(err) {
+		t.Errorf("expected %v and got %v", "not exist", err)
+	}
+
+	if current.Username == "root" {
+		for _, expected := range []types.StatusCode{types.StatusEnforced, types.StatusSatisfied} {
+			status, err = r.EnsureCertificate(context.Background(), cert)
+			if err != nil || status != expected {
+				t.Errorf("expected %v and got %v %v", expected, status, err)
+			}
+		}
+
+		st, err := os.Stat(cert.KeyPath)
+		if err != nil || st.Mode().Perm() != 0600 {
+			t.Errorf("expected %v and got %v %v", os.FileMode(0600), st, err)
+		}
+	}
+
+	// test hostname and timezone, the test server prints test for both
+	status, err = r.EnsureHostname(context.Background(), "test")
+	if err != nil || status != types.StatusSatisfied {
+		t.Errorf("expected %v and got %v %v", types.StatusSatisfied, status, err)
+	}
+
+	status, err = r.EnsureHostname(context.Background(), "web1")
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	status, err = r.EnsureTimezone(context.Background(), "Europe/Berlin")
+	if err != nil || status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v %v", types.StatusEnforced, status, err)
+	}
+
+	// test fetch
+	fetchPath := t.TempDir() + "/localhost/index.php"
+	status, err = r.Fetch(context.Background(), "testdata/index.php", fetchPath)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusEnforced {
+		t.Errorf("expected %v and got %v", types.StatusEnforced, status)
+	}
+
+	status, err = r.Fetch(context.Background(), "testdata/index.php", fetchPath)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if status != types.StatusSatisfied {
+		t.Errorf("expected %v and got %v", types.StatusSatisfied, status)
+	}
+
 	// test Installs
-	err = r.Install(context.Background(), []types.Rule{
-		"apache2",
+	hold := true
+	err = r.Install(context.Background(), []types.Package{
+		{
+			Name: "apache2",
+		},
+		{
+			Name:    "php",
+			Version: "2:8.1*",
+			Hold:    &hold,
+		},
+		{
+			Deb: "testdata/php-hello_1.0.2-1_all.deb",
+		},
 	})
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
@@ -113,7 +672,9 @@ func TestRemote(t *testing.T) {
 
 	// test Removes
 	err = r.Remove(context.Background(), []types.Rule{
-		"apache2",
+		{
+			Name: "apache2",
+		},
 	})
 	if err != nil {
 		t.Errorf("expected no errors and got err=%v", err.Error())
@@ -0,0 +1,48 @@
+package types
+
+// Facts are the details gathered from a host
+type Facts struct {
+	OS         OS                `json:"os"`
+	Kernel     string            `json:"kernel"`
+	CPU        CPU               `json:"cpu"`
+	Memory     Memory            `json:"memory"`
+	Disks      []Disk            `json:"disks"`
+	Interfaces []Interface       `json:"interfaces"`
+	Packages   map[string]string `json:"packages"`
+	Services   []string          `json:"services"`
+}
+
+// OS is the distribution of the host as in /etc/os-release
+type OS struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Version  string   `json:"version"`
+	Codename string   `json:"codename"`
+	Like     []string `json:"like"`
+}
+
+// CPU holds the processors of the host
+type CPU struct {
+	Count int    `json:"count"`
+	Model string `json:"model"`
+}
+
+// Memory holds the memory of the host in MB
+type Memory struct {
+	TotalMB     int `json:"total_mb"`
+	AvailableMB int `json:"available_mb"`
+}
+
+// Disk is a mounted filesystem, sizes are in MB
+type Disk struct {
+	Device      string `json:"device"`
+	Mount       string `json:"mount"`
+	SizeMB      int    `json:"size_mb"`
+	AvailableMB int    `json:"available_mb"`
+}
+
+// Interface is a network interface with its addresses
+type Interface struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+}
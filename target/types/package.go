@@ -10,6 +10,8 @@ const (
 
 	StatusRestarted = iota
 	StatusStarted
+	StatusStopped
+	StatusReloaded
 )
 
 // APT is a apt/dpkg package or service
@@ -17,9 +19,14 @@ type APT struct {
 	Name   string
 	Status Status
 	User   string
+
+	// Version constraint and hold flag of installed packages
+	Version string
+	Hold    *bool
 }
 
 // Service return bool if the apt is a service
 func (p *APT) Service() bool {
-	return p.Status == StatusStarted || p.Status == StatusRestarted
+	return p.Status == StatusStarted || p.Status == StatusRestarted ||
+		p.Status == StatusStopped || p.Status == StatusReloaded
 }
@@ -1,18 +1,508 @@
 package types
 
-// Rule is a string name of a apckage or a service
-type Rule string
+import (
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Rule is the name of a package or a service. It's either the name or a
+// mapping with the name and a when-condition.
+type Rule struct {
+	Name string `yaml:"name"`
+	When string `yaml:"when,omitempty"`
+}
 
 // Rules is list of packages or services
 type Rules []Rule
 
+// Package is an install rule. It's either the package name or a mapping
+// with a version constraint and a hold flag.
+type Package struct {
+	Name string `yaml:"name"`
+
+	// Version is the exact version to install, or a pattern like 8.1.*
+	Version string `yaml:"version,omitempty"`
+
+	// Hold stops upgrades of the package when true and allows them again when
+	// false. It's left untouched when not set.
+	Hold *bool `yaml:"hold,omitempty"`
+
+	// Deb is the local path of a .deb package to install, the name and the
+	// version are read from the package
+	Deb string `yaml:"deb,omitempty"`
+
+	// When is the condition for installing the package on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Packages is list of install rules
+type Packages []Package
+
+// UnmarshalYAML accepts a plain name as well as the mapping form
+func (r *Rule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		r.Name = value.Value
+		return nil
+	}
+
+	type plain Rule
+	return value.Decode((*plain)(r))
+}
+
+// MarshalYAML writes the rule as plain name when there is no condition
+func (r Rule) MarshalYAML() (interface{}, error) {
+	if r.When == "" {
+		return r.Name, nil
+	}
+
+	type plain Rule
+	return plain(r), nil
+}
+
+// UnmarshalYAML accepts a plain package name as well as the mapping form
+func (p *Package) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		p.Name = value.Value
+		return nil
+	}
+
+	type plain Package
+	return value.Decode((*plain)(p))
+}
+
+// MarshalYAML writes the package as plain name when there are no constraints
+func (p Package) MarshalYAML() (interface{}, error) {
+	if p.Version == "" && p.Hold == nil && p.Deb == "" && p.When == "" {
+		return p.Name, nil
+	}
+
+	type plain Package
+	return plain(p), nil
+}
+
+// the states of a transfer_files entry
+const (
+	FileStateFile      = "file"
+	FileStateDirectory = "directory"
+	FileStateLink      = "link"
+	FileStateAbsent    = "absent"
+)
+
 // File a file to be transfered from local machine to the server
 type File struct {
-	Owner      string `yaml:"owner,omitempty"`
-	Group      string `yaml:"group,omitempty"`
+	// Owner and Group are names or numeric ids, the group defaults to the
+	// primary group of the owner
+	Owner string `yaml:"owner,omitempty"`
+	Group string `yaml:"group,omitempty"`
+
+	// UID and GID are used instead of Owner and Group when they are set
+	UID *int `yaml:"uid,omitempty"`
+	GID *int `yaml:"gid,omitempty"`
+
 	Mode       int    `yaml:"mode,omitempty"`
 	RemotePath string `yaml:"remotepath,omitempty"`
 	LocalPath  string `yaml:"localpath,omitempty"`
+
+	// Content is the inline content of the file, it's used instead of LocalPath
+	Content string `yaml:"content,omitempty"`
+
+	// State is one of file (default), directory, link or absent
+	State string `yaml:"state,omitempty"`
+
+	// Target is the path the link points to when State is link
+	Target string `yaml:"target,omitempty"`
+
+	// Template renders LocalPath with text/template and the facts of the host
+	// before pushing it
+	Template bool `yaml:"template,omitempty"`
+
+	// When is the condition for pushing the file to the host
+	When string `yaml:"when,omitempty"`
+}
+
+// the states of lines and blocks in a file
+const (
+	StatePresent = "present"
+	StateAbsent  = "absent"
+)
+
+// Line ensures a single line in a remote file
+type Line struct {
+	Path string `yaml:"path"`
+
+	// Regexp matches the line to replace, or the lines to remove when the state
+	// is absent. The exact Line is matched when it's empty.
+	Regexp string `yaml:"regexp,omitempty"`
+	Line   string `yaml:"line,omitempty"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// Create creates the file when it does not exist
+	Create bool `yaml:"create,omitempty"`
+
+	// When is the condition for editing the file on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Block ensures a block of lines between marker lines in a remote file
+type Block struct {
+	Path  string `yaml:"path"`
+	Block string `yaml:"block,omitempty"`
+
+	// Marker is the marker line with {mark} replaced by BEGIN and END, it
+	// defaults to "# {mark} goconf managed block"
+	Marker string `yaml:"marker,omitempty"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// Create creates the file when it does not exist
+	Create bool `yaml:"create,omitempty"`
+
+	// When is the condition for editing the file on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// PHPIni sets values in the php.ini of a PHP version and SAPI
+type PHPIni struct {
+	// Version is the PHP version like 8.1, it's detected when empty
+	Version string `yaml:"version,omitempty"`
+
+	// SAPI is apache2 (default), fpm or cli
+	SAPI string `yaml:"sapi,omitempty"`
+
+	// Path is the php.ini to edit instead of /etc/php/<version>/<sapi>/php.ini
+	Path string `yaml:"path,omitempty"`
+
+	// Section is the ini section of the settings, PHP by default
+	Section  string            `yaml:"section,omitempty"`
+	Settings map[string]string `yaml:"settings"`
+
+	// When is the condition for editing the php.ini on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// the states of apache sites, modules and confs
+const (
+	StateEnabled  = "enabled"
+	StateDisabled = "disabled"
+)
+
+// ApacheItem is an apache site, module or conf. It's either the name or a
+// mapping with the state and a when-condition.
+type ApacheItem struct {
+	Name string `yaml:"name"`
+
+	// State is enabled (default) or disabled
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for the item on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// UnmarshalYAML accepts a plain name as well as the mapping form
+func (a *ApacheItem) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		a.Name = value.Value
+		return nil
+	}
+
+	type plain ApacheItem
+	return value.Decode((*plain)(a))
+}
+
+// MarshalYAML writes the item as plain name when it's enabled unconditionally
+func (a ApacheItem) MarshalYAML() (interface{}, error) {
+	if (a.State == "" || a.State == StateEnabled) && a.When == "" {
+		return a.Name, nil
+	}
+
+	type plain ApacheItem
+	return plain(a), nil
+}
+
+// Apache holds the sites, modules and confs of apache2 which are enabled with
+// a2ensite, a2enmod and a2enconf
+type Apache struct {
+	Sites   []ApacheItem `yaml:"sites,omitempty"`
+	Modules []ApacheItem `yaml:"modules,omitempty"`
+	Confs   []ApacheItem `yaml:"confs,omitempty"`
+}
+
+// Sync mirrors a local directory tree to a remote directory, only changed files
+// are transferred
+type Sync struct {
+	LocalPath  string `yaml:"localpath"`
+	RemotePath string `yaml:"remotepath"`
+	Owner      string `yaml:"owner,omitempty"`
+	Group      string `yaml:"group,omitempty"`
+
+	// Mode and DirMode are applied to all files and directories, the local
+	// modes are kept when not set
+	Mode    int `yaml:"mode,omitempty"`
+	DirMode int `yaml:"dir_mode,omitempty"`
+
+	// Delete removes the remote files which do not exist locally
+	Delete bool `yaml:"delete,omitempty"`
+
+	// When is the condition for syncing the directory to the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Fetch is a remote file which is downloaded to the local directory of the host
+type Fetch struct {
+	RemotePath string `yaml:"remotepath"`
+
+	// LocalPath is the local directory, the file is stored as
+	// <localpath>/<host address>/<remotepath>
+	LocalPath string `yaml:"localpath,omitempty"`
+
+	// When is the condition for fetching the file from the host
+	When string `yaml:"when,omitempty"`
+}
+
+// User is a local user of the host. Settings which are not set are left
+// untouched on existing users.
+type User struct {
+	Name string `yaml:"name"`
+	UID  *int   `yaml:"uid,omitempty"`
+
+	// Group is the primary group, Groups are the supplementary groups the user
+	// is added to
+	Group  string   `yaml:"group,omitempty"`
+	Groups []string `yaml:"groups,omitempty"`
+
+	Shell  string `yaml:"shell,omitempty"`
+	Home   string `yaml:"home,omitempty"`
+	System bool   `yaml:"system,omitempty"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for ensuring the user on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// UserGroup is a local group of the host
+type UserGroup struct {
+	Name   string `yaml:"name"`
+	GID    *int   `yaml:"gid,omitempty"`
+	System bool   `yaml:"system,omitempty"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for ensuring the group on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// AuthorizedKey adds the public keys of a local file to the authorized_keys of
+// a user, or removes them when the state is absent
+type AuthorizedKey struct {
+	User      string `yaml:"user"`
+	LocalPath string `yaml:"localpath"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for ensuring the keys on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Cron is a cron job of the host, it's written to /etc/cron.d/<name>
+type Cron struct {
+	// Name is the name of the cron file with letters, digits, - and _ only
+	Name string `yaml:"name"`
+
+	// Schedule is the time and date fields like "*/5 * * * *" or @daily
+	Schedule string `yaml:"schedule"`
+
+	// User runs the job, root by default
+	User string `yaml:"user,omitempty"`
+	Job  string `yaml:"job"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for ensuring the cron job on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Sysctl is a kernel parameter, it's applied and persisted in /etc/sysctl.d
+type Sysctl struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+
+	// When is the condition for setting the parameter on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Firewall holds the default policies and the rules of the host firewall
+type Firewall struct {
+	// Backend is ufw (default) or nftables
+	Backend string `yaml:"backend,omitempty"`
+
+	// Incoming and Outgoing are the default policies allow, deny or reject,
+	// they are left untouched when empty
+	Incoming string `yaml:"incoming,omitempty"`
+	Outgoing string `yaml:"outgoing,omitempty"`
+
+	Rules []FirewallRule `yaml:"rules,omitempty"`
+}
+
+// FirewallRule allows or blocks incoming traffic to a port or an ufw app
+type FirewallRule struct {
+	// Port is a port like 80 or a range like 8000:8100, App is an app profile
+	// of ufw like "Apache Full"
+	Port string `yaml:"port,omitempty"`
+	App  string `yaml:"app,omitempty"`
+
+	// Proto is tcp or udp, both when empty
+	Proto string `yaml:"proto,omitempty"`
+
+	// From is the source address or network, any when empty
+	From string `yaml:"from,omitempty"`
+
+	// Action is allow (default), deny, reject or limit
+	Action string `yaml:"action,omitempty"`
+
+	// State is present (default) or absent
+	State string `yaml:"state,omitempty"`
+
+	// When is the condition for the rule on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Certificate pushes a TLS certificate with its private key and chain. The
+// files are validated before they are pushed.
+type Certificate struct {
+	Name string `yaml:"name"`
+
+	// Cert, Key and Chain are the local PEM files, the chain is optional
+	Cert  string `yaml:"cert"`
+	Key   string `yaml:"key"`
+	Chain string `yaml:"chain,omitempty"`
+
+	// CertPath, KeyPath and ChainPath are the remote paths, by default
+	// /etc/ssl/certs/<name>.crt, /etc/ssl/private/<name>.key and
+	// /etc/ssl/certs/<name>.chain.crt
+	CertPath  string `yaml:"cert_path,omitempty"`
+	KeyPath   string `yaml:"key_path,omitempty"`
+	ChainPath string `yaml:"chain_path,omitempty"`
+
+	// Group may read the key, e.g. ssl-cert, only root can read it otherwise
+	Group string `yaml:"group,omitempty"`
+
+	// Notify is the service reloaded when the files change, the web server of
+	// the profile by default
+	Notify string `yaml:"notify,omitempty"`
+
+	// When is the condition for pushing the certificate to the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Reboot reboots the host when the installed packages need it, the remaining
+// rules are applied once the host is back
+type Reboot struct {
+	// IfRequired reboots the host when /var/run/reboot-required exists
+	IfRequired bool `yaml:"if_required,omitempty"`
+
+	// Timeout is the number of seconds to wait for the host to come back, 300
+	// by default
+	Timeout int `yaml:"timeout,omitempty"`
+}
+
+// Service is a service rule with the desired state of the service on the host.
+// Enabled and Masked are left untouched when not set.
+type Service struct {
+	Name string `yaml:"name"`
+
+	// State is one of started, stopped, restarted or reloaded
+	State   string `yaml:"state,omitempty"`
+	Enabled *bool  `yaml:"enabled,omitempty"`
+	Masked  *bool  `yaml:"masked,omitempty"`
+
+	// When is the condition for ensuring the service on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Guards make commands idempotent, the command is skipped when Creates exists,
+// Unless succeeds or OnlyIf fails
+type Guards struct {
+	Creates string `yaml:"creates,omitempty"`
+	Unless  string `yaml:"unless,omitempty"`
+	OnlyIf  string `yaml:"onlyif,omitempty"`
+}
+
+// Command is an arbitrary command run on the host
+type Command struct {
+	Name    string            `yaml:"name,omitempty"`
+	Command string            `yaml:"command"`
+	Dir     string            `yaml:"dir,omitempty"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	User    string            `yaml:"user,omitempty"`
+
+	Guards `yaml:",inline"`
+
+	// When is the condition for running the command on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Script is a local script which is uploaded and run on the host
+type Script struct {
+	Name string            `yaml:"name,omitempty"`
+	Path string            `yaml:"path"`
+	Args []string          `yaml:"args,omitempty"`
+	Dir  string            `yaml:"dir,omitempty"`
+	Env  map[string]string `yaml:"env,omitempty"`
+	User string            `yaml:"user,omitempty"`
+
+	Guards `yaml:",inline"`
+
+	// When is the condition for running the script on the host
+	When string `yaml:"when,omitempty"`
+}
+
+// Repository is an apt source with its signing key
+type Repository struct {
+	Name string `yaml:"name"`
+
+	// Format is deb822 for a .sources file or list for a .list file, it
+	// defaults to deb822
+	Format        string   `yaml:"format,omitempty"`
+	Types         []string `yaml:"types,omitempty"`
+	URIs          []string `yaml:"uris"`
+	Suites        []string `yaml:"suites"`
+	Components    []string `yaml:"components,omitempty"`
+	Architectures []string `yaml:"architectures,omitempty"`
+
+	// Key is the local path of the ascii armored or binary signing key
+	Key string `yaml:"key,omitempty"`
+}
+
+// Apt holds the options for apt
+type Apt struct {
+	// CacheValidTime is the max age of the apt cache in seconds, after which
+	// `apt-get update` runs even if no sources changed
+	CacheValidTime int `yaml:"cache_valid_time,omitempty"`
+
+	// Conffile is the policy for modified config files on upgrades, keep (default)
+	// keeps the local file and new installs the maintainer's version
+	Conffile string `yaml:"conffile,omitempty"`
+
+	// LockTimeout is the seconds to wait for the dpkg lock, 300 by default
+	LockTimeout int `yaml:"lock_timeout,omitempty"`
+
+	// Preseed values are set in debconf before installing packages
+	Preseed []Debconf `yaml:"preseed,omitempty"`
+}
+
+// Debconf is a debconf value as passed to debconf-set-selections
+type Debconf struct {
+	Package  string `yaml:"package"`
+	Question string `yaml:"question"`
+	Type     string `yaml:"type"`
+	Value    string `yaml:"value"`
 }
 
 // Host is the basic config for ssh a server
@@ -21,14 +511,67 @@ type Host struct {
 	Port     int    `yaml:"port"`
 	User     string `yaml:"user"`
 	Password string `yaml:"password"`
+
+	// PackageManager is one of apt, dnf, yum or apk. It's detected from the
+	// host OS when empty.
+	PackageManager string `yaml:"package_manager,omitempty"`
+}
+
+// Group holds the settings shared by the hosts of a group
+type Group struct {
+	// Profile is the stack profile of the hosts without their own profile
+	Profile string `yaml:"profile,omitempty"`
+
+	// Vars are the defaults of the host variables
+	Vars map[string]string `yaml:"vars,omitempty"`
 }
 
 // Config the available server config and commands
 type Config struct {
-	Host    Host   `yaml:"host"`
-	Install Rules  `yaml:"install,omitempty"`
-	Remove  Rules  `yaml:"remove,omitempty"`
-	Run     Rules  `yaml:"run,omitempty"`
-	Restart Rules  `yaml:"restart,omitempty"`
-	Files   []File `yaml:"transfer_files,omitempty"`
+	Host Host `yaml:"host"`
+
+	// Profile is the stack profile like apache-modphp or nginx-fpm whose
+	// rules are added to the config
+	Profile string `yaml:"profile,omitempty"`
+
+	// Groups are the groups of the host, their vars and profile are used
+	// when the host does not set them
+	Groups []string `yaml:"groups,omitempty"`
+
+	Install Packages `yaml:"install,omitempty"`
+	Reboot  Reboot   `yaml:"reboot,omitempty"`
+	Remove  Rules    `yaml:"remove,omitempty"`
+	Run     Rules    `yaml:"run,omitempty"`
+	Restart Rules    `yaml:"restart,omitempty"`
+	Files   []File   `yaml:"transfer_files,omitempty"`
+	Sync    []Sync   `yaml:"sync,omitempty"`
+	Fetch   []Fetch  `yaml:"fetch,omitempty"`
+	Lines   []Line   `yaml:"lines,omitempty"`
+	Blocks  []Block  `yaml:"blocks,omitempty"`
+	PHPIni  []PHPIni `yaml:"php_ini,omitempty"`
+	Apache  Apache   `yaml:"apache,omitempty"`
+
+	// Hostname and Timezone are left untouched when empty
+	Hostname string   `yaml:"hostname,omitempty"`
+	Timezone string   `yaml:"timezone,omitempty"`
+	Sysctl   []Sysctl `yaml:"sysctl,omitempty"`
+	Cron     []Cron   `yaml:"cron,omitempty"`
+
+	Firewall Firewall `yaml:"firewall,omitempty"`
+
+	Certificates []Certificate `yaml:"certificates,omitempty"`
+
+	Users          []User          `yaml:"users,omitempty"`
+	UserGroups     []UserGroup     `yaml:"user_groups,omitempty"`
+	AuthorizedKeys []AuthorizedKey `yaml:"authorized_keys,omitempty"`
+
+	Services []Service `yaml:"services,omitempty"`
+	Exec     []Command `yaml:"exec,omitempty"`
+	Scripts  []Script  `yaml:"scripts,omitempty"`
+
+	Apt          Apt          `yaml:"apt,omitempty"`
+	Repositories []Repository `yaml:"repositories,omitempty"`
+
+	// Vars are the host variables for conditions and templates
+	Vars map[string]string `yaml:"vars,omitempty"`
 }
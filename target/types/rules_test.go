@@ -0,0 +1,108 @@
+package types
+
+import (
+	"testing"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func TestPackageYAML(t *testing.T) {
+	content := `
+install:
+  - apache2
+  - name: php
+    version: 8.1.*
+    hold: true
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(content), &config)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(config.Install) != 2 {
+		t.Fatalf("expected %v and got %v", 2, len(config.Install))
+	}
+
+	if config.Install[0].Name != "apache2" || config.Install[0].Hold != nil {
+		t.Errorf("expected %v and got %v", "apache2", config.Install[0])
+	}
+
+	php := config.Install[1]
+	if php.Name != "php" || php.Version != "8.1.*" || php.Hold == nil || !*php.Hold {
+		t.Errorf("expected %v and got %v", "php 8.1.* held", php)
+	}
+
+	out, err := yaml.Marshal(config.Install)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "- apache2\n- name: php\n  version: 8.1.*\n  hold: true\n"
+	if string(out) != expected {
+		t.Errorf("expected %v and got %v", expected, string(out))
+	}
+}
+
+func TestRuleYAML(t *testing.T) {
+	content := `
+run:
+  - apache2
+  - name: php8.1-fpm
+    when: facts.os.version == "22.04"
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(content), &config)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(config.Run) != 2 {
+		t.Fatalf("expected %v and got %v", 2, len(config.Run))
+	}
+
+	if config.Run[0].Name != "apache2" || config.Run[1].When != `facts.os.version == "22.04"` {
+		t.Errorf("expected %v and got %v", "apache2 and conditional php8.1-fpm", config.Run)
+	}
+
+	out, err := yaml.Marshal(config.Run)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "- apache2\n- name: php8.1-fpm\n  when: facts.os.version == \"22.04\"\n"
+	if string(out) != expected {
+		t.Errorf("expected %v and got %v", expected, string(out))
+	}
+}
+
+func TestApacheYAML(t *testing.T) {
+	content := `
+apache:
+  modules:
+    - rewrite
+  sites:
+    - 000-default
+    - name: legacy
+      state: disabled
+`
+	var config Config
+	err := yaml.Unmarshal([]byte(content), &config)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if len(config.Apache.Sites) != 2 || config.Apache.Sites[1].State != StateDisabled || config.Apache.Modules[0].Name != "rewrite" {
+		t.Errorf("expected %v and got %v", "rewrite, 000-default and disabled legacy", config.Apache)
+	}
+
+	out, err := yaml.Marshal(config.Apache.Sites)
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := "- 000-default\n- name: legacy\n  state: disabled\n"
+	if string(out) != expected {
+		t.Errorf("expected %v and got %v", expected, string(out))
+	}
+}
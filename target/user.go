@@ -0,0 +1,415 @@
+package target
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack/target/types"
+)
+
+// passwdEntry is a user of the passwd database
+type passwdEntry struct {
+	name  string
+	uid   int
+	gid   int
+	home  string
+	shell string
+}
+
+// groupEntry is a group of the group database
+type groupEntry struct {
+	name    string
+	gid     int
+	members []string
+}
+
+// parsePasswd parses a line of getent passwd
+func parsePasswd(line string) (passwdEntry, error) {
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) != 7 {
+		return passwdEntry{}, fmt.Errorf("invalid passwd entry %q", line)
+	}
+
+	uid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return passwdEntry{}, errors.Wrapf(err, "invalid uid of %s", fields[0])
+	}
+
+	gid, err := strconv.Atoi(fields[3])
+	if err != nil {
+		return passwdEntry{}, errors.Wrapf(err, "invalid gid of %s", fields[0])
+	}
+
+	return passwdEntry{name: fields[0], uid: uid, gid: gid, home: fields[5], shell: fields[6]}, nil
+}
+
+// parseGroup parses a line of getent group
+func parseGroup(line string) (groupEntry, error) {
+	fields := strings.Split(strings.TrimSpace(line), ":")
+	if len(fields) != 4 {
+		return groupEntry{}, fmt.Errorf("invalid group entry %q", line)
+	}
+
+	gid, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return groupEntry{}, errors.Wrapf(err, "invalid gid of %s", fields[0])
+	}
+
+	members := []string{}
+	if fields[3] != "" {
+		members = strings.Split(fields[3], ",")
+	}
+
+	return groupEntry{name: fields[0], gid: gid, members: members}, nil
+}
+
+// userAddCmd returns the useradd cmd creating u, regular users get a home
+func userAddCmd(u types.User) string {
+	args := []string{"sudo useradd"}
+	if u.System {
+		args = append(args, "-r")
+	} else {
+		args = append(args, "-m")
+	}
+
+	if u.UID != nil {
+		args = append(args, fmt.Sprintf("-u %d", *u.UID))
+	}
+
+	if u.Group != "" {
+		args = append(args, "-g "+shellQuote(u.Group))
+	}
+
+	if len(u.Groups) > 0 {
+		args = append(args, "-G "+shellQuote(strings.Join(u.Groups, ",")))
+	}
+
+	if u.Shell != "" {
+		args = append(args, "-s "+shellQuote(u.Shell))
+	}
+
+	if u.Home != "" {
+		args = append(args, "-d "+shellQuote(u.Home))
+	}
+
+	return strings.Join(append(args, shellQuote(u.Name)), " ")
+}
+
+// userModArgs returns the usermod args changing the current user to u, gid is
+// the id of the primary group of u or -1 when it's not set. The user is only
+// added to the missing supplementary groups, other groups are kept.
+func userModArgs(u types.User, current passwdEntry, groups []string, gid int) []string {
+	args := []string{}
+	if u.UID != nil && *u.UID != current.uid {
+		args = append(args, fmt.Sprintf("-u %d", *u.UID))
+	}
+
+	if gid >= 0 && gid != current.gid {
+		args = append(args, "-g "+shellQuote(u.Group))
+	}
+
+	member := map[string]bool{}
+	for _, g := range groups {
+		member[g] = true
+	}
+
+	missing := []string{}
+	for _, g := range u.Groups {
+		if !member[g] {
+			missing = append(missing, g)
+		}
+	}
+
+	if len(missing) > 0 {
+		args = append(args, "-a -G "+shellQuote(strings.Join(missing, ",")))
+	}
+
+	if u.Shell != "" && u.Shell != current.shell {
+		args = append(args, "-s "+shellQuote(u.Shell))
+	}
+
+	if u.Home != "" && u.Home != current.home {
+		args = append(args, "-d "+shellQuote(u.Home)+" -m")
+	}
+
+	return args
+}
+
+// change runs the cmd changing the target and prints it
+func (r *Remote) change(cmd string) error {
+	fmt.Printf("trying to %s on %s ...\n", strings.TrimPrefix(cmd, "sudo "), r.addr)
+
+	res, err := r.RunCmd(cmd, bytes.NewBufferString(""))
+	if err != nil {
+		return errors.Wrapf(err, "could not run %s", cmd)
+	}
+
+	if !res.Success() {
+		return errors.Errorf("%s failed: %s", cmd, strings.TrimSpace(res.Stderr.String()))
+	}
+
+	return nil
+}
+
+// changeUsers runs the cmd changing users or groups, the cached databases are
+// read again by the next lookup
+func (r *Remote) changeUsers(cmd string) error {
+	defer r.resetIDs()
+
+	return r.change(cmd)
+}
+
+// EnsureUser creates, updates or removes a local user
+func (r *Remote) EnsureUser(ctx context.Context, u types.User) (types.StatusCode, error) {
+	current, err := r.lookupUser(u.Name)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	switch u.State {
+	case "", types.StatePresent:
+	case types.StateAbsent:
+		if current == nil {
+			return types.StatusSatisfied, nil
+		}
+
+		err = r.changeUsers("sudo userdel " + shellQuote(u.Name))
+		if err != nil {
+			return types.StatusFailed, err
+		}
+		return types.StatusEnforced, nil
+	default:
+		return types.StatusFailed, fmt.Errorf("unknown state %s of user %s", u.State, u.Name)
+	}
+
+	if current == nil {
+		err = r.changeUsers(userAddCmd(u))
+		if err != nil {
+			return types.StatusFailed, err
+		}
+		return types.StatusEnforced, nil
+	}
+
+	gid := -1
+	if u.Group != "" {
+		group, err := r.lookupGroup(u.Group)
+		if err != nil {
+			return types.StatusFailed, err
+		}
+
+		if group == nil {
+			return types.StatusFailed, fmt.Errorf("group %s of user %s does not exist", u.Group, u.Name)
+		}
+		gid = group.gid
+	}
+
+	groups, err := r.memberOf(u.Name)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	args := userModArgs(u, *current, groups, gid)
+	if len(args) == 0 {
+		return types.StatusSatisfied, nil
+	}
+
+	err = r.changeUsers(fmt.Sprintf("sudo usermod %s %s", strings.Join(args, " "), shellQuote(u.Name)))
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	return types.StatusEnforced, nil
+}
+
+// EnsureGroup creates, updates or removes a local group
+func (r *Remote) EnsureGroup(ctx context.Context, g types.UserGroup) (types.StatusCode, error) {
+	current, err := r.lookupGroup(g.Name)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	cmd := ""
+	switch g.State {
+	case "", types.StatePresent:
+		if current == nil {
+			args := []string{"sudo groupadd"}
+			if g.System {
+				args = append(args, "-r")
+			}
+			if g.GID != nil {
+				args = append(args, fmt.Sprintf("-g %d", *g.GID))
+			}
+			cmd = strings.Join(append(args, shellQuote(g.Name)), " ")
+		} else if g.GID != nil && *g.GID != current.gid {
+			cmd = fmt.Sprintf("sudo groupmod -g %d %s", *g.GID, shellQuote(g.Name))
+		}
+	case types.StateAbsent:
+		if current != nil {
+			cmd = "sudo groupdel " + shellQuote(g.Name)
+		}
+	default:
+		return types.StatusFailed, fmt.Errorf("unknown state %s of group %s", g.State, g.Name)
+	}
+
+	if cmd == "" {
+		return types.StatusSatisfied, nil
+	}
+
+	err = r.changeUsers(cmd)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	return types.StatusEnforced, nil
+}
+
+// keyTypes are the prefixes of the public key types
+var keyTypes = []string{"ssh-", "ecdsa-", "sk-"}
+
+// keyID returns the type and the key of an authorized_keys line without its
+// options and comment, so the same key is matched with another comment
+func keyID(line string) string {
+	fields := strings.Fields(line)
+	for i := 0; i+1 < len(fields); i++ {
+		for _, prefix := range keyTypes {
+			if strings.HasPrefix(fields[i], prefix) {
+				return fields[i] + " " + fields[i+1]
+			}
+		}
+	}
+
+	return strings.TrimSpace(line)
+}
+
+// parseKeys returns the public keys of a local key file
+func parseKeys(content string) []string {
+	keys := []string{}
+	for _, line := range splitLines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+
+	return keys
+}
+
+// editKeys returns the authorized_keys content with the keys added, or removed
+// when the state is absent
+func editKeys(content string, keys []string, state string) (string, error) {
+	lines := splitLines(content)
+
+	ids := map[string]bool{}
+	for _, key := range keys {
+		ids[keyID(key)] = true
+	}
+
+	switch state {
+	case "", types.StatePresent:
+		present := map[string]bool{}
+		for _, line := range lines {
+			present[keyID(line)] = true
+		}
+
+		for _, key := range keys {
+			if !present[keyID(key)] {
+				lines = append(lines, key)
+				present[keyID(key)] = true
+			}
+		}
+	case types.StateAbsent:
+		kept := []string{}
+		for _, line := range lines {
+			if !ids[keyID(line)] {
+				kept = append(kept, line)
+			}
+		}
+		lines = kept
+	default:
+		return "", fmt.Errorf("unknown state %s", state)
+	}
+
+	return joinLines(lines), nil
+}
+
+// EnsureAuthorizedKey adds the public keys of a local file to the
+// authorized_keys of the user, or removes them. The .ssh directory and the
+// file are owned by the user and only accessible by the user.
+func (r *Remote) EnsureAuthorizedKey(ctx context.Context, k types.AuthorizedKey) (types.StatusCode, error) {
+	content, err := os.ReadFile(k.LocalPath)
+	if err != nil {
+		return types.StatusFailed, errors.Wrapf(err, "unable to read file %s", k.LocalPath)
+	}
+
+	keys := parseKeys(string(content))
+	if len(keys) == 0 {
+		return types.StatusFailed, fmt.Errorf("no public key in %s", k.LocalPath)
+	}
+
+	user, err := r.lookupUser(k.User)
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if user == nil {
+		return types.StatusFailed, fmt.Errorf("user %s does not exist", k.User)
+	}
+
+	client, err := r.sftpClient()
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "could not get sftp client")
+	}
+
+	dir := path.Join(user.home, ".ssh")
+	p := path.Join(dir, "authorized_keys")
+
+	if k.State != types.StateAbsent {
+		if _, err := client.Stat(dir); os.IsNotExist(err) {
+			err = client.MkdirAll(dir)
+			if err != nil {
+				return types.StatusFailed, errors.Wrapf(err, "unable to create directory %s", dir)
+			}
+
+			err = client.Chmod(dir, 0700)
+			if err != nil {
+				return types.StatusFailed, errors.Wrap(err, "chmod error")
+			}
+
+			err = client.Chown(dir, user.uid, user.gid)
+			if err != nil {
+				return types.StatusFailed, errors.Wrap(err, "chown error")
+			}
+		}
+	}
+
+	changed, err := r.editFile(p, true, func(content string) (string, error) {
+		return editKeys(content, keys, k.State)
+	})
+	if err != nil {
+		return types.StatusFailed, err
+	}
+
+	if !changed {
+		return types.StatusSatisfied, nil
+	}
+
+	err = client.Chmod(p, 0600)
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "chmod error")
+	}
+
+	err = client.Chown(p, user.uid, user.gid)
+	if err != nil {
+		return types.StatusFailed, errors.Wrap(err, "chown error")
+	}
+
+	fmt.Printf("%s is updated on %s\n", p, r.addr)
+	return types.StatusEnforced, nil
+}
@@ -0,0 +1,95 @@
+package target
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack/target/types"
+)
+
+func TestParsePasswd(t *testing.T) {
+	entry, err := parsePasswd("deploy:x:1001:1002:Deploy:/home/deploy:/bin/bash\n")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := passwdEntry{name: "deploy", uid: 1001, gid: 1002, home: "/home/deploy", shell: "/bin/bash"}
+	if entry != expected {
+		t.Errorf("expected %v and got %v", expected, entry)
+	}
+
+	for _, line := range []string{"test", "deploy:x:a:1002::/home/deploy:/bin/bash"} {
+		_, err = parsePasswd(line)
+		if err == nil {
+			t.Errorf("%s: expected error and got nil", line)
+		}
+	}
+
+	group, err := parseGroup("www-data:x:33:deploy,php\n")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	if group.name != "www-data" || group.gid != 33 || strings.Join(group.members, ",") != "deploy,php" {
+		t.Errorf("expected %v and got %v", "www-data 33 deploy,php", group)
+	}
+}
+
+func TestUserCmds(t *testing.T) {
+	uid := 1001
+	u := types.User{Name: "deploy", UID: &uid, Group: "deploy", Groups: []string{"www-data", "adm"}, Shell: "/bin/bash"}
+
+	cmd := userAddCmd(u)
+	expected := "sudo useradd -m -u 1001 -g 'deploy' -G 'www-data,adm' -s '/bin/bash' 'deploy'"
+	if cmd != expected {
+		t.Errorf("expected %q and got %q", expected, cmd)
+	}
+
+	current := passwdEntry{name: "deploy", uid: 1001, gid: 1001, home: "/home/deploy", shell: "/bin/bash"}
+	args := userModArgs(u, current, []string{"deploy", "www-data"}, 1001)
+	if strings.Join(args, " ") != "-a -G 'adm'" {
+		t.Errorf("expected %q and got %q", "-a -G 'adm'", strings.Join(args, " "))
+	}
+
+	args = userModArgs(u, current, []string{"deploy", "www-data", "adm", "sudo"}, 1001)
+	if len(args) != 0 {
+		t.Errorf("expected %v and got %v", 0, args)
+	}
+
+	u.Home = "/srv/deploy"
+	args = userModArgs(u, current, []string{"www-data", "adm"}, 1005)
+	expected = "-g 'deploy' -d '/srv/deploy' -m"
+	if strings.Join(args, " ") != expected {
+		t.Errorf("expected %q and got %q", expected, strings.Join(args, " "))
+	}
+}
+
+func TestEditKeys(t *testing.T) {
+	current := "ssh-ed25519 AAAAC3one alice@laptop\n"
+	keys := parseKeys("# deploy keys\nssh-ed25519 AAAAC3one alice@desktop\n\nssh-rsa AAAAB3two bob\n")
+
+	content, err := editKeys(current, keys, "")
+	if err != nil {
+		t.Errorf("expected no errors and got err=%v", err.Error())
+	}
+
+	expected := current + "ssh-rsa AAAAB3two bob\n"
+	if content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	content, err = editKeys(expected, keys, "")
+	if err != nil || content != expected {
+		t.Errorf("expected %q and got %q", expected, content)
+	}
+
+	content, err = editKeys(`from="10.0.0.1" ssh-rsa AAAAB3two bob`+"\nssh-ed25519 AAAAC3three carol\n", keys, types.StateAbsent)
+	if err != nil || content != "ssh-ed25519 AAAAC3three carol\n" {
+		t.Errorf("expected %q and got %q", "ssh-ed25519 AAAAC3three carol\n", content)
+	}
+
+	_, err = editKeys(current, keys, "missing")
+	if err == nil {
+		t.Errorf("expected error and got nil")
+	}
+}